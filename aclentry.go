@@ -96,7 +96,5 @@ func (a *ACLEntry) Equal(e *ACLEntry) bool {
 // little endian order, which is the representation required
 // for the Setxattr(...) call
 func (a *ACLEntry) ToByteSlice(result *bytes.Buffer) {
-	binary.Write(result, binary.LittleEndian, a.tag)
-	binary.Write(result, binary.LittleEndian, a.perm)
-	binary.Write(result, binary.LittleEndian, a.id)
+	result.Write(a.AppendBinary(result.AvailableBuffer()))
 }
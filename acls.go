@@ -4,57 +4,107 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"log/slog"
 	"math"
 	"os"
 	"sort"
 	"strings"
 	"syscall"
 
-	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
 
-// ACL handles Posix ACL data
+// ACL handles Posix ACL data.
+// The read only methods (String, Equal, ToByteSlice, Get*) never
+// modify the ACL and can be called concurrently. Mutating methods
+// require external synchronization, see SyncACL.
 type ACL struct {
 	version uint32
 	entries []*ACLEntry
+	logger  *slog.Logger
+}
+
+// ACLOption configures an ACL created via NewACL
+type ACLOption func(*ACL)
+
+// WithLogger sets the logger the ACL emits its debug events to.
+// Without this option the ACL does not log at all.
+func WithLogger(l *slog.Logger) ACLOption {
+	return func(a *ACL) {
+		a.logger = l
+	}
 }
 
 // NewACL returns a new ACL instance
-func NewACL() *ACL {
-	return &ACL{
-		version: 2,
+func NewACL(opts ...ACLOption) *ACL {
+	a := &ACL{
+		version: POSIX_ACL_XATTR_VERSION,
 		entries: []*ACLEntry{},
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
+// SetLogger sets the logger the ACL emits its debug events to.
+// A nil logger silences the ACL again.
+func (a *ACL) SetLogger(l *slog.Logger) {
+	a.logger = l
+}
+
+// log returns the configured logger or a discarding one
+// if none is set, so callers never need to nil check.
+func (a *ACL) log() *slog.Logger {
+	if a.logger == nil {
+		return discardLogger
+	}
+	return a.logger
+}
+
+// discardLogger is used whenever no logger is configured
+var discardLogger = slog.New(slog.DiscardHandler)
+
 // Load loads the attr defined POSIX.ACL type (access or default)
 // from the given filepath
 func (a *ACL) Load(fsPath string, attr ACLAttr) error {
+	_, err := a.load(fsPath, attr)
+	return err
+}
+
+// load implements Load. exists reports whether the attr was actually
+// present on fsPath or the ACL was bootstrapped from the file mode.
+func (a *ACL) load(fsPath string, attr ACLAttr) (exists bool, err error) {
+	buf := getXattrBuffer()
+	defer putXattrBuffer(buf)
+	return a.loadWith(fsPath, attr, buf)
+}
+
+// loadWith implements load, reading the attr into buf
+func (a *ACL) loadWith(fsPath string, attr ACLAttr, buf *[]byte) (exists bool, err error) {
 	a.entries = []*ACLEntry{}
-	a.version = 2
+	a.version = POSIX_ACL_XATTR_VERSION
 
 	// Get the ACL as an extended attribute.
-	attrSize, err := unix.Getxattr(fsPath, string(attr), nil)
+	attrValue, err := getxattr(fsPath, string(attr), buf)
 	switch {
 	case err == unix.ENODATA:
 		// there is not acl attached to the fsPath object
 		// so bootstrap it with regular chown type of information
-		return a.bootstrapACL(fsPath)
+		if err := a.bootstrapACL(fsPath); err != nil {
+			return false, err
+		}
+		a.log().Debug("acl bootstrapped from file mode", "path", fsPath, "attr", attr, "entries", len(a.entries))
+		return false, nil
 	case err != nil:
-		return err
+		return false, err
 	}
 
-	// Allocate a buffer to hold the ACL data.
-	attrValue := make([]byte, attrSize)
-
-	// Retrieve the ACL data.
-	_, err = unix.Getxattr(fsPath, string(attr), attrValue)
-	if err != nil {
-		return err
+	if err := a.parse(attrValue); err != nil {
+		return false, err
 	}
-
-	return a.parse(attrValue)
+	a.log().Debug("acl loaded", "path", fsPath, "attr", attr, "entries", len(a.entries))
+	return true, nil
 }
 
 // bootstrapACL loads the regular file permissions as ACL entries
@@ -89,19 +139,20 @@ func (a *ACL) bootstrapACL(fsPath string) error {
 // Apply applies the ACL with its ACLEntries to as
 // either access or default ACLs to the given filesstem path
 func (a *ACL) Apply(fsPath string, attr ACLAttr) error {
-	b := &bytes.Buffer{}
-	a.ToByteSlice(b)
-	return unix.Setxattr(fsPath, string(attr), b.Bytes(), 0)
+	return a.ApplyWithMode(fsPath, attr, ApplyModeAny)
 }
 
 // ToByteSlice return the ACL in its byte slice representation
-// read to be used by Setxattr(...)
-func (a *ACL) ToByteSlice(result *bytes.Buffer) {
-	a.sort()
-	binary.Write(result, binary.LittleEndian, a.version)
-	for _, e := range a.entries {
-		e.ToByteSlice(result)
+// read to be used by Setxattr(...). Nothing is written and an
+// *UnsupportedVersionError returned for versions without registered
+// ACLCodec.
+func (a *ACL) ToByteSlice(result *bytes.Buffer) error {
+	b, err := a.AppendBinary(result.AvailableBuffer())
+	if err != nil {
+		return err
 	}
+	result.Write(b)
+	return nil
 }
 
 // AddEntry adds the given entry to the ACL
@@ -111,7 +162,7 @@ func (a *ACL) ToByteSlice(result *bytes.Buffer) {
 func (a *ACL) AddEntry(e *ACLEntry) error {
 	deleted := a.DeleteEntry(e)
 	if deleted != nil {
-		log.Debugf("Existing entry %q deleted", deleted.String())
+		a.log().Debug("existing acl entry replaced", "tag", Tag2String(deleted.tag), "id", deleted.id, "oldPerm", PermUintToString(deleted.perm), "newPerm", PermUintToString(e.perm))
 	}
 	a.entries = append(a.entries, e)
 	return nil
@@ -164,14 +215,13 @@ func (a *ACL) EntryExists(e *ACLEntry) int {
 
 // Equal returns true if the given ACL equals the actual ACL
 func (a *ACL) Equal(e *ACL) bool {
-	// sort before comparing
-	a.sort()
-	e.sort()
 	if !(len(a.entries) == len(e.entries) && a.version == e.version) {
 		return false
 	}
-	for id, val := range a.entries {
-		if !val.Equal(e.entries[id]) {
+	// compare sorted copies, so neither ACL is modified
+	ours, theirs := a.sortedEntries(), e.sortedEntries()
+	for id, val := range ours {
+		if !val.Equal(theirs[id]) {
 			return false
 		}
 	}
@@ -179,37 +229,32 @@ func (a *ACL) Equal(e *ACL) bool {
 }
 
 // parse parses the byte slice that contains the ACLEntries
-// and add them to a.entries.
+// and add them to a.entries. The entries are decoded by the
+// ACLCodec of the version in the header, an
+// *UnsupportedVersionError is returned if there is none.
 func (a *ACL) parse(b []byte) error {
 	if len(b) < 4 {
 		return fmt.Errorf("expecting at least a 32 bit header, got %d", len(b)*4)
 	}
-	a.version = binary.LittleEndian.Uint32(b[:4])
-
-	remainder := b[4:]
-	var err error
-	for {
-		e := &ACLEntry{}
-		remainder, err = e.parse(remainder)
-		if err != nil {
-			return err
-		}
-		a.entries = append(a.entries, e)
-		if len(remainder) == 0 {
-			break
-		}
+	version := binary.LittleEndian.Uint32(b[:4])
+	c, err := LookupACLCodec(version)
+	if err != nil {
+		return err
 	}
-
+	entries, err := c.DecodeEntries(b[4:])
+	if err != nil {
+		return err
+	}
+	a.version = version
+	a.entries = append(a.entries, entries...)
 	return nil
 }
 
 // String returns a human readable for of the ACL
 func (a *ACL) String() string {
 	sb := &strings.Builder{}
-	// sort before generating
-	a.sort()
 
-	for _, e := range a.entries {
+	for _, e := range a.sortedEntries() {
 		sb.WriteString(e.String())
 		sb.WriteString("\n")
 	}
@@ -221,7 +266,83 @@ func (a *ACL) String() string {
 // by their tag number. To apply the ACL the tags ned to
 // be in ascending order
 func (a *ACL) sort() {
-	sort.Slice(a.entries, func(i, j int) bool {
-		return a.entries[i].tag < a.entries[j].tag
+	sortEntries(a.entries)
+}
+
+// sortedEntries returns a sorted copy of a.entries.
+// Used by all read only methods, so that they never
+// modify the ACL and are safe for concurrent use.
+func (a *ACL) sortedEntries() []*ACLEntry {
+	result := make([]*ACLEntry, len(a.entries))
+	copy(result, a.entries)
+	sortEntries(result)
+	return result
+}
+
+// qualifiedEntries returns the sorted entries of the ACL with the ID of
+// all entries but USER and GROUP set to ACL_UNDEFINED_ID
+func qualifiedEntries(a *ACL) []*ACLEntry {
+	result := a.sortedEntries()
+	for i, e := range result {
+		if e.tag != TAG_ACL_USER && e.tag != TAG_ACL_GROUP && e.id != ACL_UNDEFINED_ID {
+			result[i] = NewEntry(e.tag, ACL_UNDEFINED_ID, e.perm)
+		}
+	}
+	return result
+}
+
+// sortEntries sorts the given entries by tag and, for
+// entries carrying the same tag, by id.
+func sortEntries(entries []*ACLEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entryLess(entries[i], entries[j])
 	})
 }
+
+// entryLess reports whether entry a sorts before entry b
+func entryLess(a, b *ACLEntry) bool {
+	if a.tag != b.tag {
+		return a.tag < b.tag
+	}
+	return a.id < b.id
+}
+
+// Clone returns a deep copy of the ACL. The copy shares
+// no state with the original besides the logger, so it can
+// be modified independently, e.g. to customize a template.
+func (a *ACL) Clone() *ACL {
+	result := &ACL{
+		version: a.version,
+		entries: make([]*ACLEntry, 0, len(a.entries)),
+		logger:  a.logger,
+	}
+	for _, e := range a.entries {
+		result.entries = append(result.entries, NewEntry(e.tag, e.id, e.perm))
+	}
+	return result
+}
+
+// CalculateMask sets the MASK entry to the union of the permissions
+// of the group class (named users, owning group and named groups),
+// like setfacl does after modifying an ACL.
+func (a *ACL) CalculateMask() {
+	var perm uint16
+	for _, e := range a.entries {
+		switch e.tag {
+		case TAG_ACL_USER, TAG_ACL_GROUP_OBJ, TAG_ACL_GROUP:
+			perm |= e.perm
+		}
+	}
+	a.AddEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, perm))
+}
+
+// hasNamedEntries reports whether the ACL contains
+// named USER or GROUP entries
+func (a *ACL) hasNamedEntries() bool {
+	for _, e := range a.entries {
+		if e.tag == TAG_ACL_USER || e.tag == TAG_ACL_GROUP {
+			return true
+		}
+	}
+	return false
+}
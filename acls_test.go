@@ -3,11 +3,13 @@ package acls
 import (
 	"bytes"
 	"encoding/hex"
+	"log/slog"
 	"math"
 	"os"
 	"os/user"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -233,7 +235,9 @@ func TestACL_ToByteSlice(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &bytes.Buffer{}
-			tt.acl.ToByteSlice(b)
+			if err := tt.acl.ToByteSlice(b); err != nil {
+				t.Fatalf("ToByteSlice() error = %v", err)
+			}
 			result := hex.EncodeToString(b.Bytes())
 			if result != tt.result {
 				t.Errorf("byte representations do not match. expected %q, got %q", tt.result, result)
@@ -810,3 +814,44 @@ func TestACL_GetEntries_AllEntriesPresent(t *testing.T) {
 		}
 	}
 }
+
+func TestACL_Logger(t *testing.T) {
+	f, err := os.CreateTemp("", "acltest")
+	if err != nil {
+		t.Fatalf("failed to create file for testing %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	a := NewACL(WithLogger(logger))
+	if err := a.Load(f.Name(), PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	a.AddEntry(NewEntry(TAG_ACL_OTHER, math.MaxUint32, 4))
+	if err := a.Apply(f.Name(), PosixACLAccess); err != nil {
+		t.Fatalf("failed applying acl to %q: %v", f.Name(), err)
+	}
+
+	for _, want := range []string{
+		"acl bootstrapped from file mode",
+		"existing acl entry replaced",
+		"acl applied",
+		"path=" + f.Name(),
+		"attr=system.posix_acl_access",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected log output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	// without a logger nothing must be emitted and nothing must panic
+	a.SetLogger(nil)
+	buf.Reset()
+	a.AddEntry(NewEntry(TAG_ACL_OTHER, math.MaxUint32, 0))
+	if buf.Len() != 0 {
+		t.Errorf("expected no log output without logger, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,115 @@
+package acls
+
+// Merge returns the union of a and e: entries present in either ACL,
+// with the permissions of entries present in both ORed.
+// The mask of the result is recalculated.
+func (a *ACL) Merge(e *ACL) *ACL {
+	return a.combine(e, func(x, y *ACLEntry) *ACLEntry {
+		switch {
+		case x == nil:
+			return y
+		case y == nil:
+			return x
+		}
+		return x.WithPerm(y.perm)
+	})
+}
+
+// Intersect returns the entries present in both a and e, with their
+// permissions ANDed. The required entries are kept, with the permissions
+// of the ACL holding them if only one does. The mask of the result is
+// recalculated.
+func (a *ACL) Intersect(e *ACL) *ACL {
+	return a.combine(e, func(x, y *ACLEntry) *ACLEntry {
+		switch {
+		case x != nil && y != nil:
+			return x.WithExactPerm(x.perm & y.perm)
+		case x == nil:
+			x = y
+		}
+		switch x.tag {
+		case TAG_ACL_USER_OBJ, TAG_ACL_GROUP_OBJ, TAG_ACL_OTHER:
+			return x
+		}
+		return nil
+	})
+}
+
+// Subtract returns a with the permission bits of the matching entries
+// of e removed. Entries are kept even if no permission is left, as an
+// entry without permissions still restricts the principal.
+// The mask of the result is recalculated.
+func (a *ACL) Subtract(e *ACL) *ACL {
+	return a.combine(e, func(x, y *ACLEntry) *ACLEntry {
+		if x == nil || y == nil {
+			return x
+		}
+		return x.WithoutPerm(y.perm)
+	})
+}
+
+// SubtractEntries returns a without the named USER and GROUP entries
+// present in e, regardless of their permissions. The required entries
+// are kept. The mask of the result is recalculated.
+func (a *ACL) SubtractEntries(e *ACL) *ACL {
+	return a.combine(e, func(x, y *ACLEntry) *ACLEntry {
+		if x != nil && y != nil && (x.tag == TAG_ACL_USER || x.tag == TAG_ACL_GROUP) {
+			return nil
+		}
+		return x
+	})
+}
+
+// Overlay returns a with the entries of e added, replacing entries
+// with the same tag and qualifier like AddEntry does.
+// The mask of the result is recalculated.
+func (a *ACL) Overlay(e *ACL) *ACL {
+	return a.combine(e, func(x, y *ACLEntry) *ACLEntry {
+		if y == nil {
+			return x
+		}
+		return y
+	})
+}
+
+// combine returns a new ACL holding the result of op for the entries
+// of a and e, matched by tag and qualifier. x is nil if only e holds
+// the entry and y if only a does, op returns nil to drop the entry.
+// Qualifiers of the owner entries are ignored and left undefined.
+// MASK entries are not combined, instead the mask is recalculated from
+// the group class if the result holds named entries and left out
+// otherwise. The result keeps version and logger of a.
+func (a *ACL) combine(e *ACL, op func(x, y *ACLEntry) *ACLEntry) *ACL {
+	result := &ACL{version: a.version, logger: a.logger}
+	ours := qualifiedEntries(a)
+	theirs := qualifiedEntries(e)
+	add := func(x, y *ACLEntry) {
+		if r := op(x, y); r != nil {
+			result.entries = append(result.entries, NewEntry(r.tag, r.id, r.perm))
+		}
+	}
+	for _, x := range ours {
+		if x.tag != TAG_ACL_MASK {
+			add(x, findEntry(theirs, x))
+		}
+	}
+	for _, y := range theirs {
+		if y.tag != TAG_ACL_MASK && findEntry(ours, y) == nil {
+			add(nil, y)
+		}
+	}
+	if result.hasNamedEntries() {
+		result.CalculateMask()
+	}
+	return result
+}
+
+// findEntry returns the entry of entries with the tag and id of e
+func findEntry(entries []*ACLEntry, e *ACLEntry) *ACLEntry {
+	for _, x := range entries {
+		if x.equalTagID(e) {
+			return x
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package acls
+
+import (
+	"math"
+	"testing"
+)
+
+func TestACL_Algebra(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	base := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+		NewEntry(TAG_ACL_USER, 2000, 4),
+		NewEntry(TAG_ACL_GROUP_OBJ, 1000, 5),
+		NewEntry(TAG_ACL_GROUP, 3000, 5),
+		NewEntry(TAG_ACL_MASK, u, 5),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	}}
+	grants := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER, 2000, 2),
+		NewEntry(TAG_ACL_GROUP, 3000, 4),
+		NewEntry(TAG_ACL_GROUP, 4000, 7),
+		NewEntry(TAG_ACL_MASK, u, 0),
+	}}
+	tests := []struct {
+		name string
+		op   func(a, e *ACL) *ACL
+		want []*ACLEntry
+	}{
+		{
+			name: "merge",
+			op:   (*ACL).Merge,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 2000, 6),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 3000, 5),
+				NewEntry(TAG_ACL_GROUP, 4000, 7),
+				NewEntry(TAG_ACL_MASK, u, 7),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+		},
+		{
+			name: "intersect",
+			op:   (*ACL).Intersect,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 2000, 0),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 3000, 4),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+		},
+		{
+			name: "subtract",
+			op:   (*ACL).Subtract,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 2000, 4),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 3000, 1),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+		},
+		{
+			name: "subtract entries",
+			op:   (*ACL).SubtractEntries,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+		},
+		{
+			name: "overlay",
+			op:   (*ACL).Overlay,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 2000, 2),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 3000, 4),
+				NewEntry(TAG_ACL_GROUP, 4000, 7),
+				NewEntry(TAG_ACL_MASK, u, 7),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := base.String()
+			got := tt.op(base, grants)
+			want := &ACL{version: 2, entries: tt.want}
+			if !got.Equal(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if base.String() != before {
+				t.Errorf("receiver modified")
+			}
+		})
+	}
+}
+
+func TestACL_Intersect_requiredEntries(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	named := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER, 2000, 7),
+		NewEntry(TAG_ACL_MASK, u, 7),
+	}}
+	full := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 6),
+		NewEntry(TAG_ACL_USER, 2000, 5),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+		NewEntry(TAG_ACL_MASK, u, 5),
+		NewEntry(TAG_ACL_OTHER, u, 4),
+	}}
+	other := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 0),
+		NewEntry(TAG_ACL_OTHER, u, 5),
+	}}
+	tests := []struct {
+		name string
+		a, e *ACL
+		want []*ACLEntry
+	}{
+		{
+			name: "named receiver",
+			a:    named,
+			e:    full,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 6),
+				NewEntry(TAG_ACL_USER, 2000, 5),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+		},
+		{
+			name: "named argument",
+			a:    full,
+			e:    named,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 6),
+				NewEntry(TAG_ACL_USER, 2000, 5),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+		},
+		{
+			name: "both required",
+			a:    full,
+			e:    other,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 6),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 0),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.a.Intersect(tt.e)
+			want := &ACL{version: 2, entries: tt.want}
+			if !got.Equal(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
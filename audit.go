@@ -0,0 +1,196 @@
+package acls
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Baseline provides the expected ACLs of an audit
+type Baseline interface {
+	// Expected returns the expected ACL of the given attr of fsPath,
+	// which is located below root. current is the ACL found on disk.
+	// ok is false if the baseline has no expectation for the path.
+	Expected(root string, fsPath string, d fs.DirEntry, attr ACLAttr, current *ACL) (expected *ACL, ok bool, err error)
+}
+
+// Expected implements Baseline, the expected ACL is the
+// current one transformed according to the policy
+func (p *Policy) Expected(_ string, fsPath string, d fs.DirEntry, attr ACLAttr, current *ACL) (*ACL, bool, error) {
+	expected := current.Clone()
+	if err := p.Transform()(fsPath, d, attr, expected); err != nil {
+		return nil, false, err
+	}
+	return expected, true, nil
+}
+
+// AuditFinding lists the deviations of an ACL attribute of a path from
+// the baseline. Within Diff, DiffAdded denotes an extra entry, DiffRemoved
+// a missing entry and DiffModified an entry with changed permissions.
+type AuditFinding struct {
+	Path string      `json:"path"`
+	Attr ACLAttr     `json:"attr"`
+	Diff []EntryDiff `json:"diff"`
+}
+
+// AuditSummary holds the counters of an audit
+type AuditSummary struct {
+	// Scanned is the number of paths visited
+	Scanned int64 `json:"scanned"`
+	// Deviating is the number of paths with at least one finding
+	Deviating int64 `json:"deviating"`
+	// Untracked is the number of paths the baseline has no expectation for
+	Untracked int64 `json:"untracked"`
+	// Failed is the number of paths that could not be audited
+	Failed int64 `json:"failed"`
+	// Missing is the number of entries missing compared to the baseline
+	Missing int64 `json:"missing"`
+	// Extra is the number of entries not present in the baseline
+	Extra int64 `json:"extra"`
+	// Changed is the number of entries with permissions deviating from the baseline
+	Changed int64 `json:"changed"`
+}
+
+// AuditReport is the outcome of Audit
+type AuditReport struct {
+	Root    string       `json:"root"`
+	Summary AuditSummary `json:"summary"`
+	// Findings are ordered by path and attr
+	Findings []*AuditFinding `json:"findings"`
+	// Untracked holds the paths the baseline has no expectation for
+	Untracked []string     `json:"untracked,omitempty"`
+	Errors    []*BulkError `json:"errors,omitempty"`
+}
+
+// Audit compares the ACLs of the tree below root with the baseline,
+// without modifying anything. opts.Attrs defaults to both access and
+// default ACLs. A missing default ACL is compared as empty ACL.
+// Progress is reported with Changed counting the deviating paths.
+func Audit(ctx context.Context, root string, baseline Baseline, opts BulkOptions) (*AuditReport, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Attrs) == 0 {
+		opts.Attrs = []ACLAttr{PosixACLAccess, PosixACLDefault}
+	}
+	b := newBulkRun(opts)
+	mu := sync.Mutex{}
+	report := &AuditReport{Root: root, Findings: []*AuditFinding{}}
+
+	err = walkTree(ctx, root, opts.Workers, opts.RateLimit, func(fsPath string, d fs.DirEntry, err error) {
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		findings := []*AuditFinding{}
+		untracked := false
+		for _, attr := range b.attrsFor(d) {
+			current, err := loadForCompare(fsPath, attr, opts.Logger)
+			if err != nil {
+				b.record(fsPath, false, &BulkError{Path: fsPath, Attr: attr, Err: err})
+				return
+			}
+			expected, ok, err := baseline.Expected(root, fsPath, d, attr, current)
+			if err != nil {
+				b.record(fsPath, false, &BulkError{Path: fsPath, Attr: attr, Err: err})
+				return
+			}
+			if !ok {
+				untracked = true
+				continue
+			}
+			if diff := expected.Diff(current); len(diff) > 0 {
+				findings = append(findings, &AuditFinding{Path: fsPath, Attr: attr, Diff: diff})
+			}
+		}
+
+		mu.Lock()
+		report.Findings = append(report.Findings, findings...)
+		if untracked {
+			report.Untracked = append(report.Untracked, fsPath)
+		}
+		mu.Unlock()
+		b.record(fsPath, len(findings) > 0, nil)
+	})
+
+	result := b.finish()
+	report.Errors = result.Errors
+	report.Summary.Scanned = result.Scanned
+	report.Summary.Deviating = result.Changed
+	report.Summary.Failed = result.Failed
+	report.Summary.Untracked = int64(len(report.Untracked))
+	for _, f := range report.Findings {
+		for _, d := range f.Diff {
+			switch d.Kind {
+			case DiffAdded:
+				report.Summary.Extra++
+			case DiffRemoved:
+				report.Summary.Missing++
+			case DiffModified:
+				report.Summary.Changed++
+			}
+		}
+	}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Path != report.Findings[j].Path {
+			return report.Findings[i].Path < report.Findings[j].Path
+		}
+		return report.Findings[i].Attr < report.Findings[j].Attr
+	})
+	sort.Strings(report.Untracked)
+	return report, err
+}
+
+// auditKind returns the audit term of a DiffKind
+func auditKind(k DiffKind) string {
+	switch k {
+	case DiffAdded:
+		return "extra"
+	case DiffRemoved:
+		return "missing"
+	}
+	return "changed"
+}
+
+// JSON renders the report as indented JSON
+func (r *AuditReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// WriteCSV writes one row per deviating entry with the columns
+// path, attr, finding (missing, extra or changed), tag, id,
+// expected permission and actual permission
+func (r *AuditReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"path", "attr", "finding", "tag", "id", "expected", "actual"}); err != nil {
+		return err
+	}
+	for _, f := range r.Findings {
+		for _, d := range f.Diff {
+			expected, actual := PermUintToString(d.OldPerm), PermUintToString(d.NewPerm)
+			switch d.Kind {
+			case DiffAdded:
+				expected = ""
+			case DiffRemoved:
+				actual = ""
+			}
+			id := ""
+			if d.ID != ACL_UNDEFINED_ID {
+				id = strconv.FormatUint(uint64(d.ID), 10)
+			}
+			row := []string{f.Path, string(f.Attr), auditKind(d.Kind), Tag2String(d.Tag), id, expected, actual}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,118 @@
+package acls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAudit_Dump(t *testing.T) {
+	root := createTestTree(t, "a/file1", "file2")
+	file1 := filepath.Join(root, "a", "file1")
+	file2 := filepath.Join(root, "file2")
+	addOnDisk(t, file2, NewEntry(TAG_ACL_USER, 4711, PermRead))
+
+	baseline, _, err := DumpTree(context.Background(), root, BulkOptions{})
+	if err != nil {
+		t.Fatalf("DumpTree() unexpected error %v", err)
+	}
+
+	// drift: one extra, one missing, one changed entry and a new file
+	addOnDisk(t, file1, NewEntry(TAG_ACL_GROUP, 5558, PermRead))
+	a := NewACL()
+	if err := a.Load(file2, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	a.DeleteEntry(NewEntry(TAG_ACL_USER, 4711, 0))
+	a.AddEntry(NewEntry(TAG_ACL_USER, 4712, PermRead))
+	if err := a.Apply(file2, PosixACLAccess); err != nil {
+		t.Fatalf("failed applying ACL %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "new"), nil, 0o600); err != nil {
+		t.Fatalf("failed creating file %v", err)
+	}
+
+	report, err := Audit(context.Background(), root, baseline, BulkOptions{})
+	if err != nil {
+		t.Fatalf("Audit() unexpected error %v", err)
+	}
+	want := AuditSummary{Scanned: 5, Deviating: 2, Untracked: 1, Missing: 1, Extra: 2}
+	if report.Summary != want {
+		t.Errorf("unexpected summary %+v, want %+v\n%v", report.Summary, want, report.Findings)
+	}
+	if len(report.Untracked) != 1 || report.Untracked[0] != filepath.Join(root, "new") {
+		t.Errorf("unexpected untracked paths %v", report.Untracked)
+	}
+
+	b, err := report.JSON()
+	if err != nil {
+		t.Fatalf("JSON() unexpected error %v", err)
+	}
+	if !json.Valid(b) || !bytes.Contains(b, []byte(`"kind": "removed"`)) {
+		t.Errorf("unexpected JSON report %s", b)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := report.WriteCSV(buf); err != nil {
+		t.Fatalf("WriteCSV() unexpected error %v", err)
+	}
+	csv := buf.String()
+	for _, line := range []string{
+		"path,attr,finding,tag,id,expected,actual\n",
+		file1 + ",system.posix_acl_access,extra,GROUP,5558,,r--\n",
+		file2 + ",system.posix_acl_access,missing,USER,4711,r--,\n",
+		file2 + ",system.posix_acl_access,extra,USER,4712,,r--\n",
+	} {
+		if !strings.Contains(csv, line) {
+			t.Errorf("expected CSV to contain %q, got:\n%s", line, csv)
+		}
+	}
+}
+
+func TestAudit_Policy(t *testing.T) {
+	root := createTestTree(t, "data/file1")
+	p, err := LoadPolicy(strings.NewReader(`{"rules":[{"path":"` + root + `/data/**","access":["g:5558:r-X","o::---"],"purge":true}]}`))
+	if err != nil {
+		t.Fatalf("LoadPolicy() unexpected error %v", err)
+	}
+	file1 := filepath.Join(root, "data", "file1")
+	addOnDisk(t, file1, NewEntry(TAG_ACL_USER, 4711, PermRead))
+
+	report, err := Audit(context.Background(), root, p, BulkOptions{Attrs: []ACLAttr{PosixACLAccess}})
+	if err != nil {
+		t.Fatalf("Audit() unexpected error %v", err)
+	}
+	if report.Summary.Deviating != 2 || report.Summary.Untracked != 0 {
+		t.Errorf("unexpected summary %+v", report.Summary)
+	}
+	found := false
+	for _, f := range report.Findings {
+		if f.Path != file1 {
+			continue
+		}
+		for _, d := range f.Diff {
+			if d.Kind == DiffAdded && d.Tag == TAG_ACL_USER && d.ID == 4711 {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected extra user entry on %q, got %v", file1, report.Findings)
+	}
+
+	// after reconciling there are no findings
+	if _, err := p.Reconcile(context.Background(), root, BulkOptions{}); err != nil {
+		t.Fatalf("Reconcile() unexpected error %v", err)
+	}
+	report, err = Audit(context.Background(), root, p, BulkOptions{Attrs: []ACLAttr{PosixACLAccess}})
+	if err != nil {
+		t.Fatalf("Audit() unexpected error %v", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Errorf("expected no findings after reconcile, got %v", report.Findings)
+	}
+}
@@ -0,0 +1,234 @@
+package acls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sync"
+)
+
+// TransformFunc computes the desired ACL of a path. It is called with a
+// copy of the ACL currently attached to fsPath and modifies it in place.
+// The ACL is only written if it differs from the current one afterwards.
+type TransformFunc func(fsPath string, d fs.DirEntry, attr ACLAttr, a *ACL) error
+
+// BulkOptions configures BulkApply
+type BulkOptions struct {
+	// Workers is the number of concurrent workers, defaults to GOMAXPROCS
+	Workers int
+	// RateLimit is the maximum number of paths processed per second,
+	// 0 means unlimited
+	RateLimit int
+	// Attrs are the ACL types to process, defaults to PosixACLAccess.
+	// PosixACLDefault is only processed for directories.
+	Attrs []ACLAttr
+	// Progress is called after every processed path with the current
+	// counters. Calls are serialized.
+	Progress func(BulkProgress)
+	// Logger receives debug events, nothing is logged if nil
+	Logger *slog.Logger
+	// Journal records the previous state of every ACL before
+	// it is modified, no journal is written if nil
+	Journal *Journal
+}
+
+// BulkProgress holds the counters of a bulk operation
+type BulkProgress struct {
+	// Scanned is the number of paths visited
+	Scanned int64
+	// Changed is the number of paths with at least one ACL written
+	Changed int64
+	// Failed is the number of paths that could not be processed
+	Failed int64
+}
+
+// BulkResult is the outcome of a bulk operation
+type BulkResult struct {
+	BulkProgress
+	// Errors holds the errors of all failed paths
+	Errors []*BulkError
+}
+
+// BulkError is the error that occurred processing a single path
+type BulkError struct {
+	Path string
+	Attr ACLAttr
+	Err  error
+}
+
+func (e *BulkError) Error() string {
+	if e.Attr == "" {
+		return fmt.Sprintf("%q: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%q %s: %v", e.Path, e.Attr, e.Err)
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
+
+// bulkErrorJSON is the JSON representation of a BulkError
+type bulkErrorJSON struct {
+	Path  string  `json:"path"`
+	Attr  ACLAttr `json:"attr,omitempty"`
+	Error string  `json:"error"`
+}
+
+// MarshalJSON encodes the BulkError with the error as string
+func (e *BulkError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bulkErrorJSON{Path: e.Path, Attr: e.Attr, Error: e.Err.Error()})
+}
+
+// UnmarshalJSON decodes the BulkError, the error
+// is restored as a plain error carrying the message
+func (e *BulkError) UnmarshalJSON(b []byte) error {
+	j := bulkErrorJSON{}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*e = BulkError{Path: j.Path, Attr: j.Attr, Err: errors.New(j.Error)}
+	return nil
+}
+
+// BulkApply walks the tree below root concurrently and applies the ACLs
+// computed by f. Paths whose ACL is already in the desired state are
+// not written. Failures of individual paths are collected in the result,
+// the returned error is only set if root is inaccessible or ctx got
+// cancelled, in which case the partial result is returned as well.
+func BulkApply(ctx context.Context, root string, f TransformFunc, opts BulkOptions) (*BulkResult, error) {
+	b := newBulkRun(opts)
+	err := walkTree(ctx, root, opts.Workers, opts.RateLimit, func(fsPath string, d fs.DirEntry, err error) {
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		changed := false
+		for _, attr := range b.attrsFor(d) {
+			c, err := b.applyOne(fsPath, d, attr, f)
+			if err != nil {
+				b.record(fsPath, changed, &BulkError{Path: fsPath, Attr: attr, Err: err})
+				return
+			}
+			changed = changed || c
+		}
+		b.record(fsPath, changed, nil)
+	})
+	return b.finish(), err
+}
+
+// bulkRun holds the state shared by the workers of a bulk operation
+type bulkRun struct {
+	opts   BulkOptions
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	result BulkResult
+}
+
+func newBulkRun(opts BulkOptions) *bulkRun {
+	if len(opts.Attrs) == 0 {
+		opts.Attrs = []ACLAttr{PosixACLAccess}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = discardLogger
+	}
+	return &bulkRun{
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// attrsFor returns the ACL types to process for the given entry,
+// default ACLs only exist on directories
+func (b *bulkRun) attrsFor(d fs.DirEntry) []ACLAttr {
+	if d.IsDir() {
+		return b.opts.Attrs
+	}
+	result := make([]ACLAttr, 0, len(b.opts.Attrs))
+	for _, attr := range b.opts.Attrs {
+		if attr != PosixACLDefault {
+			result = append(result, attr)
+		}
+	}
+	return result
+}
+
+// compute loads the given attr of fsPath and returns it together with
+// the desired state computed by f. exists reports whether the attr
+// actually exists on fsPath.
+func (b *bulkRun) compute(fsPath string, d fs.DirEntry, attr ACLAttr, f TransformFunc) (current *ACL, desired *ACL, exists bool, err error) {
+	current = NewACL(WithLogger(b.opts.Logger))
+	if exists, err = current.load(fsPath, attr); err != nil {
+		return nil, nil, false, err
+	}
+	desired = current.Clone()
+	if err := f(fsPath, d, attr, desired); err != nil {
+		return nil, nil, false, err
+	}
+	return current, desired, exists, nil
+}
+
+// applyOne loads the given attr of fsPath, transforms it and applies
+// it if it changed. It returns true if the ACL was written.
+func (b *bulkRun) applyOne(fsPath string, d fs.DirEntry, attr ACLAttr, f TransformFunc) (bool, error) {
+	current, desired, exists, err := b.compute(fsPath, d, attr, f)
+	if err != nil {
+		return false, err
+	}
+	if desired.Equal(current) {
+		return false, nil
+	}
+	if err := b.journal(fsPath, attr, current, exists); err != nil {
+		return false, err
+	}
+	if err := desired.Apply(fsPath, attr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// journal records the state before modification
+// if a journal is configured
+func (b *bulkRun) journal(fsPath string, attr ACLAttr, before *ACL, existed bool) error {
+	if b.opts.Journal == nil {
+		return nil
+	}
+	if err := b.opts.Journal.Record(fsPath, attr, before, existed); err != nil {
+		return fmt.Errorf("failed writing journal: %w", err)
+	}
+	return nil
+}
+
+// record updates the counters for a processed path
+// and reports the progress
+func (b *bulkRun) record(fsPath string, changed bool, err *BulkError) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.result.Scanned++
+	if changed {
+		b.result.Changed++
+	}
+	if err != nil {
+		b.result.Failed++
+		b.result.Errors = append(b.result.Errors, err)
+		b.logger.Debug("bulk path failed", "path", fsPath, "attr", err.Attr, "error", err.Err)
+	} else {
+		b.logger.Debug("bulk path processed", "path", fsPath, "changed", changed)
+	}
+	if b.opts.Progress != nil {
+		b.opts.Progress(b.result.BulkProgress)
+	}
+}
+
+// finish returns the result of the run
+func (b *bulkRun) finish() *BulkResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.logger.Debug("bulk run finished", "scanned", b.result.Scanned, "changed", b.result.Changed, "failed", b.result.Failed)
+	result := b.result
+	return &result
+}
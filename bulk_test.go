@@ -0,0 +1,92 @@
+package acls
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func addUserTransform(id uint32) TransformFunc {
+	return func(fsPath string, d fs.DirEntry, attr ACLAttr, a *ACL) error {
+		return a.AddEntry(NewEntry(TAG_ACL_USER, id, PermRead))
+	}
+}
+
+func TestBulkApply(t *testing.T) {
+	root := createTestTree(t, "a/b/file1", "a/file2", "file3")
+
+	progressCalls := int64(0)
+	opts := BulkOptions{
+		Workers: 4,
+		Attrs:   []ACLAttr{PosixACLAccess, PosixACLDefault},
+		Progress: func(p BulkProgress) {
+			atomic.AddInt64(&progressCalls, 1)
+		},
+	}
+	result, err := BulkApply(context.Background(), root, addUserTransform(4711), opts)
+	if err != nil {
+		t.Fatalf("BulkApply() unexpected error %v", err)
+	}
+	if result.Scanned != 6 || result.Changed != 6 || result.Failed != 0 {
+		t.Errorf("unexpected counters %+v", result.BulkProgress)
+	}
+	if progressCalls != 6 {
+		t.Errorf("expected 6 progress calls, got %d", progressCalls)
+	}
+
+	for _, p := range []string{".", "a", "a/b", "a/b/file1", "a/file2", "file3"} {
+		a := NewACL()
+		if err := a.Load(filepath.Join(root, p), PosixACLAccess); err != nil {
+			t.Fatalf("failed loading ACL %v", err)
+		}
+		if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) == nil {
+			t.Errorf("expected user entry on %q, got %s", p, a.String())
+		}
+	}
+
+	// second run must not write anything
+	result, err = BulkApply(context.Background(), root, addUserTransform(4711), opts)
+	if err != nil {
+		t.Fatalf("BulkApply() unexpected error %v", err)
+	}
+	if result.Scanned != 6 || result.Changed != 0 {
+		t.Errorf("expected no changes in second run, got %+v", result.BulkProgress)
+	}
+}
+
+func TestBulkApply_Failures(t *testing.T) {
+	root := createTestTree(t, "file1", "file2")
+	wantErr := errors.New("transform failed")
+	f := func(fsPath string, d fs.DirEntry, attr ACLAttr, a *ACL) error {
+		if d.Name() == "file2" {
+			return wantErr
+		}
+		return a.AddEntry(NewEntry(TAG_ACL_USER, 4711, PermRead))
+	}
+	result, err := BulkApply(context.Background(), root, f, BulkOptions{})
+	if err != nil {
+		t.Fatalf("BulkApply() unexpected error %v", err)
+	}
+	if result.Scanned != 3 || result.Changed != 2 || result.Failed != 1 {
+		t.Errorf("unexpected counters %+v", result.BulkProgress)
+	}
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0], wantErr) || result.Errors[0].Path != filepath.Join(root, "file2") {
+		t.Errorf("unexpected errors %v", result.Errors)
+	}
+}
+
+func TestBulkApply_Cancel(t *testing.T) {
+	root := createTestTree(t, "file1", "file2")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := BulkApply(ctx, root, addUserTransform(4711), BulkOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if result == nil || result.Changed != 0 {
+		t.Errorf("expected empty partial result, got %+v", result)
+	}
+}
@@ -0,0 +1,140 @@
+package acls
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// ApplyMode defines how Setxattr treats an already existing
+// or missing ACL attribute
+type ApplyMode int
+
+const (
+	// ApplyModeAny creates or replaces the ACL attribute
+	ApplyModeAny ApplyMode = 0
+	// ApplyModeCreate only creates the ACL attribute, it fails
+	// with ErrConflict if the attribute already exists
+	ApplyModeCreate ApplyMode = unix.XATTR_CREATE
+	// ApplyModeReplace only replaces the ACL attribute, it fails
+	// with ErrConflict if the attribute does not exist
+	ApplyModeReplace ApplyMode = unix.XATTR_REPLACE
+)
+
+// ErrConflict is returned if the ACL on disk does not have
+// the state the caller expected. Callers should reload and retry.
+var ErrConflict = errors.New("acl conflict")
+
+// ApplyWithMode applies the ACL to the given filesystem path
+// using the given ApplyMode.
+// The flags are passed on to Setxattr, but since Linux ignores them
+// for the POSIX ACL attributes, the existence of the attribute is
+// additionally verified before writing. ACLs of a version without
+// registered ACLCodec are rejected with an *UnsupportedVersionError.
+func (a *ACL) ApplyWithMode(fsPath string, attr ACLAttr, mode ApplyMode) error {
+	b := &bytes.Buffer{}
+	if err := a.ToByteSlice(b); err != nil {
+		return err
+	}
+	if mode != ApplyModeAny {
+		_, err := unix.Getxattr(fsPath, string(attr), nil)
+		switch {
+		case err == nil && mode == ApplyModeCreate:
+			return fmt.Errorf("%w: %s already exists on %q", ErrConflict, attr, fsPath)
+		case err == unix.ENODATA && mode == ApplyModeReplace:
+			return fmt.Errorf("%w: %s does not exist on %q", ErrConflict, attr, fsPath)
+		case err != nil && err != unix.ENODATA:
+			return err
+		}
+	}
+
+	err := unix.Setxattr(fsPath, string(attr), b.Bytes(), int(mode))
+	switch {
+	case mode == ApplyModeCreate && err == unix.EEXIST:
+		return fmt.Errorf("%w: %s already exists on %q", ErrConflict, attr, fsPath)
+	case mode == ApplyModeReplace && err == unix.ENODATA:
+		return fmt.Errorf("%w: %s does not exist on %q", ErrConflict, attr, fsPath)
+	case err != nil:
+		return err
+	}
+	a.log().Debug("acl applied", "path", fsPath, "attr", attr, "entries", len(a.entries), "mode", mode)
+	return nil
+}
+
+// applyFd applies the ACL to the open file fd, for callers that
+// resolved the file themselves
+func (a *ACL) applyFd(fd int, attr ACLAttr) error {
+	b := &bytes.Buffer{}
+	if err := a.ToByteSlice(b); err != nil {
+		return err
+	}
+	if err := unix.Fsetxattr(fd, string(attr), b.Bytes(), 0); err != nil {
+		return err
+	}
+	a.log().Debug("acl applied", "fd", fd, "attr", attr, "entries", len(a.entries))
+	return nil
+}
+
+// CompareAndApply applies the ACL to the given filesystem path only
+// if the ACL currently on disk equals old, typically the state
+// originally loaded. If the attribute did not exist when re-validating,
+// it is only created, otherwise only replaced, so a concurrent creation
+// or removal is detected as well. ErrConflict is returned if the
+// on disk state deviates from old.
+//
+// The check is optimistic, a writer that modifies the entries in
+// between re-validation and write can not be detected.
+func (a *ACL) CompareAndApply(fsPath string, attr ACLAttr, old *ACL) error {
+	return a.compareAndApply(fsPath, attr, old, nil)
+}
+
+// compareAndApply implements CompareAndApply. If validated is not nil
+// it is called with the state on disk once it has been validated and
+// before the ACL is written, an error returned by it aborts the write.
+func (a *ACL) compareAndApply(fsPath string, attr ACLAttr, old *ACL, validated func(current *ACL, exists bool) error) error {
+	current := NewACL(WithLogger(a.logger))
+	exists, err := current.load(fsPath, attr)
+	if err != nil {
+		return err
+	}
+	if !current.Equal(old) {
+		a.log().Debug("acl changed on disk", "path", fsPath, "attr", attr)
+		return fmt.Errorf("%w: %s on %q changed since it was loaded", ErrConflict, attr, fsPath)
+	}
+	if validated != nil {
+		if err := validated(current, exists); err != nil {
+			return err
+		}
+	}
+	mode := ApplyModeCreate
+	if exists {
+		mode = ApplyModeReplace
+	}
+	return a.ApplyWithMode(fsPath, attr, mode)
+}
+
+// Modify performs a read-modify-write cycle on the ACL of the given path.
+// The ACL is loaded, passed to f for modification and written back via
+// CompareAndApply. On ErrConflict the cycle is repeated up to retries
+// times. An error returned by f aborts the cycle.
+func Modify(fsPath string, attr ACLAttr, retries int, f func(a *ACL) error, opts ...ACLOption) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		a := NewACL(opts...)
+		if err = a.Load(fsPath, attr); err != nil {
+			return err
+		}
+		old := a.Clone()
+		if err = f(a); err != nil {
+			return err
+		}
+		err = a.CompareAndApply(fsPath, attr, old)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+		a.log().Debug("acl modification conflicted, retrying", "path", fsPath, "attr", attr, "attempt", attempt+1)
+	}
+	return err
+}
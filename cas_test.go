@@ -0,0 +1,151 @@
+package acls
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func createTempFile(t *testing.T) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "acltest")
+	if err != nil {
+		t.Fatalf("failed to create file for testing %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("failed closing temp file %s: %v", f.Name(), err)
+	}
+	return f.Name()
+}
+
+// addOnDisk adds the entry to the access ACL of fsPath behind the
+// back of any already loaded ACL, simulating a concurrent writer
+func addOnDisk(t *testing.T, fsPath string, e *ACLEntry) {
+	t.Helper()
+	a := NewACL()
+	if err := a.Load(fsPath, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	a.AddEntry(e)
+	if err := a.Apply(fsPath, PosixACLAccess); err != nil {
+		t.Fatalf("failed applying acl to %q: %v", fsPath, err)
+	}
+}
+
+func TestACL_ApplyWithMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		withACL      bool
+		mode         ApplyMode
+		wantConflict bool
+	}{
+		{name: "create on missing", withACL: false, mode: ApplyModeCreate, wantConflict: false},
+		{name: "create on existing", withACL: true, mode: ApplyModeCreate, wantConflict: true},
+		{name: "replace on missing", withACL: false, mode: ApplyModeReplace, wantConflict: true},
+		{name: "replace on existing", withACL: true, mode: ApplyModeReplace, wantConflict: false},
+		{name: "any on existing", withACL: true, mode: ApplyModeAny, wantConflict: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsPath := createTempFile(t)
+			if tt.withACL {
+				addOnDisk(t, fsPath, NewEntry(TAG_ACL_USER, 4711, PermRead))
+			}
+			a := NewACL()
+			if err := a.Load(fsPath, PosixACLAccess); err != nil {
+				t.Fatalf("failed loading ACL %v", err)
+			}
+			a.AddEntry(NewEntry(TAG_ACL_GROUP, 4712, PermRead))
+			err := a.ApplyWithMode(fsPath, PosixACLAccess, tt.mode)
+			if errors.Is(err, ErrConflict) != tt.wantConflict {
+				t.Errorf("ApplyWithMode() error = %v, wantConflict %v", err, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestACL_CompareAndApply(t *testing.T) {
+	fsPath := createTempFile(t)
+
+	a := NewACL()
+	if err := a.Load(fsPath, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	old := a.Clone()
+	a.AddEntry(NewEntry(TAG_ACL_USER, 1234, PermAll))
+
+	// concurrent modification
+	addOnDisk(t, fsPath, NewEntry(TAG_ACL_USER, 5555, PermRead))
+
+	if err := a.CompareAndApply(fsPath, PosixACLAccess, old); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	// reload and retry succeeds
+	if err := a.Load(fsPath, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	old = a.Clone()
+	a.AddEntry(NewEntry(TAG_ACL_USER, 1234, PermAll))
+	if err := a.CompareAndApply(fsPath, PosixACLAccess, old); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	result := NewACL()
+	if err := result.Load(fsPath, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	for _, id := range []uint32{1234, 5555} {
+		if result.GetEntry(NewEntry(TAG_ACL_USER, id, 0)) == nil {
+			t.Errorf("expected entry for user %d, got %s", id, result.String())
+		}
+	}
+}
+
+func TestModify(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		wantConflict bool
+	}{
+		{name: "conflict resolved by retry", retries: 1, wantConflict: false},
+		{name: "no retries left", retries: 0, wantConflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsPath := createTempFile(t)
+			calls := 0
+			err := Modify(fsPath, PosixACLAccess, tt.retries, func(a *ACL) error {
+				calls++
+				if calls == 1 {
+					addOnDisk(t, fsPath, NewEntry(TAG_ACL_GROUP, 9999, PermRead))
+				}
+				return a.AddEntry(NewEntry(TAG_ACL_USER, 1234, PermAll))
+			})
+			if errors.Is(err, ErrConflict) != tt.wantConflict {
+				t.Fatalf("Modify() error = %v, wantConflict %v", err, tt.wantConflict)
+			}
+			if tt.wantConflict {
+				return
+			}
+			if calls != 2 {
+				t.Errorf("expected 2 calls of the modify func, got %d", calls)
+			}
+			result := NewACL()
+			if err := result.Load(fsPath, PosixACLAccess); err != nil {
+				t.Fatalf("failed loading ACL %v", err)
+			}
+			if result.GetEntry(NewEntry(TAG_ACL_GROUP, 9999, 0)) == nil || result.GetEntry(NewEntry(TAG_ACL_USER, 1234, 0)) == nil {
+				t.Errorf("expected both concurrent and own entry, got %s", result.String())
+			}
+		})
+	}
+
+	t.Run("error of modify func aborts", func(t *testing.T) {
+		fsPath := createTempFile(t)
+		wantErr := errors.New("abort")
+		if err := Modify(fsPath, PosixACLAccess, 3, func(a *ACL) error { return wantErr }); !errors.Is(err, wantErr) {
+			t.Errorf("expected %v, got %v", wantErr, err)
+		}
+	})
+}
@@ -1,14 +1,29 @@
 package acls
 
+import (
+	"fmt"
+	"math"
+)
+
 type ACLAttr string
 
 const (
 	PosixACLAccess  ACLAttr = "system.posix_acl_access"
 	PosixACLDefault ACLAttr = "system.posix_acl_default"
+	// NFS4ACLAttr holds the NFSv4 ACL of files on NFSv4 mounts,
+	// see NFS4ACL
+	NFS4ACLAttr ACLAttr = "system.nfs4_acl"
+	// RichACLAttr holds the richacl of files on filesystems
+	// with richacl support, see RichACL
+	RichACLAttr ACLAttr = "system.richacl"
 )
 
 type Tag uint16
 
+// ACL_UNDEFINED_ID is the ID of entries that do not
+// carry a qualifier, like MASK and OTHER.
+const ACL_UNDEFINED_ID = math.MaxUint32
+
 const (
 	// Undefined ACL type.
 	TAG_ACL_UNDEFINED_FIELD = 0x0
@@ -70,3 +85,25 @@ func Tag2String(i Tag) string {
 	}
 	return result
 }
+
+// String2Tag is the inverse of Tag2String. It returns an
+// error for unknown tag names.
+func String2Tag(s string) (Tag, error) {
+	switch s {
+	case "USER_OBJ":
+		return TAG_ACL_USER_OBJ, nil
+	case "USER":
+		return TAG_ACL_USER, nil
+	case "GROUP_OBJ":
+		return TAG_ACL_GROUP_OBJ, nil
+	case "GROUP":
+		return TAG_ACL_GROUP, nil
+	case "MASK":
+		return TAG_ACL_MASK, nil
+	case "OTHER":
+		return TAG_ACL_OTHER, nil
+	case "EVERYONE":
+		return TAG_ACL_EVERYONE, nil
+	}
+	return TAG_ACL_UNDEFINED_FIELD, fmt.Errorf("unknown tag %q", s)
+}
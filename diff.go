@@ -0,0 +1,158 @@
+package acls
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DiffKind is the kind of change of an ACLEntry
+type DiffKind int
+
+const (
+	// DiffAdded the entry only exists in the new ACL
+	DiffAdded DiffKind = iota
+	// DiffRemoved the entry only exists in the old ACL
+	DiffRemoved
+	// DiffModified the entry exists in both ACLs with different permissions
+	DiffModified
+)
+
+// String returns the name of the DiffKind
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// MarshalJSON encodes the DiffKind by its name
+func (k DiffKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+// UnmarshalJSON decodes the DiffKind from its name
+func (k *DiffKind) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	for _, kind := range []DiffKind{DiffAdded, DiffRemoved, DiffModified} {
+		if kind.String() == s {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown diff kind %q", s)
+}
+
+// EntryDiff describes the change of a single ACLEntry,
+// identified by its Tag and ID
+type EntryDiff struct {
+	Kind    DiffKind
+	Tag     Tag
+	ID      uint32
+	OldPerm uint16
+	NewPerm uint16
+}
+
+// String returns the change in the form "+ USER:1000:r--",
+// "- USER:1000:r--" or "~ USER:1000:r-- -> rwx"
+func (d EntryDiff) String() string {
+	switch d.Kind {
+	case DiffAdded:
+		return "+ " + entryText(d.Tag, d.ID, d.NewPerm)
+	case DiffRemoved:
+		return "- " + entryText(d.Tag, d.ID, d.OldPerm)
+	}
+	return fmt.Sprintf("~ %s -> %s", entryText(d.Tag, d.ID, d.OldPerm), PermUintToString(d.NewPerm))
+}
+
+// entryDiffJSON is the JSON representation of the EntryDiff,
+// carrying tag and permissions in their string form
+type entryDiffJSON struct {
+	Kind    DiffKind `json:"kind"`
+	Tag     string   `json:"tag"`
+	ID      uint32   `json:"id"`
+	OldPerm string   `json:"oldPerm,omitempty"`
+	NewPerm string   `json:"newPerm,omitempty"`
+}
+
+// MarshalJSON encodes the EntryDiff with tag and permissions
+// in their string form
+func (d EntryDiff) MarshalJSON() ([]byte, error) {
+	j := entryDiffJSON{Kind: d.Kind, Tag: Tag2String(d.Tag), ID: d.ID}
+	if d.Kind != DiffAdded {
+		j.OldPerm = PermUintToString(d.OldPerm)
+	}
+	if d.Kind != DiffRemoved {
+		j.NewPerm = PermUintToString(d.NewPerm)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes the EntryDiff from its JSON representation
+func (d *EntryDiff) UnmarshalJSON(b []byte) error {
+	j := entryDiffJSON{}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	tag, err := String2Tag(j.Tag)
+	if err != nil {
+		return err
+	}
+	*d = EntryDiff{Kind: j.Kind, Tag: tag, ID: j.ID}
+	if j.OldPerm != "" {
+		if d.OldPerm, err = PermStringToUint(j.OldPerm); err != nil {
+			return err
+		}
+	}
+	if j.NewPerm != "" {
+		if d.NewPerm, err = PermStringToUint(j.NewPerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryText returns the compact "TAG:ID:perm" form of an entry.
+// The ID is omitted for entries without qualifier.
+func entryText(tag Tag, id uint32, perm uint16) string {
+	if id == ACL_UNDEFINED_ID {
+		return fmt.Sprintf("%s::%s", Tag2String(tag), PermUintToString(perm))
+	}
+	return fmt.Sprintf("%s:%d:%s", Tag2String(tag), id, PermUintToString(perm))
+}
+
+// Diff returns the changes required to turn the ACL into the given one.
+// Entries are matched by Tag and ID, the result is ordered like the
+// sorted entries of both ACLs. Like the kernel, Diff ignores the ID of
+// entries other than USER and GROUP, so a bootstrapped ACL carrying the
+// owner's IDs matches the same ACL read from disk. An empty result
+// means applying e would not change the ACL.
+func (a *ACL) Diff(e *ACL) []EntryDiff {
+	result := []EntryDiff{}
+	ours, theirs := qualifiedEntries(a), qualifiedEntries(e)
+	i, j := 0, 0
+	for i < len(ours) || j < len(theirs) {
+		switch {
+		case j >= len(theirs) || (i < len(ours) && entryLess(ours[i], theirs[j])):
+			result = append(result, EntryDiff{Kind: DiffRemoved, Tag: ours[i].tag, ID: ours[i].id, OldPerm: ours[i].perm})
+			i++
+		case i >= len(ours) || entryLess(theirs[j], ours[i]):
+			result = append(result, EntryDiff{Kind: DiffAdded, Tag: theirs[j].tag, ID: theirs[j].id, NewPerm: theirs[j].perm})
+			j++
+		default:
+			if ours[i].perm != theirs[j].perm {
+				result = append(result, EntryDiff{Kind: DiffModified, Tag: ours[i].tag, ID: ours[i].id, OldPerm: ours[i].perm, NewPerm: theirs[j].perm})
+			}
+			i++
+			j++
+		}
+	}
+	return result
+}
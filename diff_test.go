@@ -0,0 +1,101 @@
+package acls
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestACL_Diff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  []*ACLEntry
+		new  []*ACLEntry
+		want []string
+	}{
+		{
+			name: "equal",
+			old:  []*ACLEntry{NewEntry(TAG_ACL_USER_OBJ, 1000, 7), NewEntry(TAG_ACL_OTHER, math.MaxUint32, 5)},
+			new:  []*ACLEntry{NewEntry(TAG_ACL_OTHER, math.MaxUint32, 5), NewEntry(TAG_ACL_USER_OBJ, 1000, 7)},
+			want: []string{},
+		},
+		{
+			name: "added, removed and modified",
+			old: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+				NewEntry(TAG_ACL_USER, 2000, 6),
+				NewEntry(TAG_ACL_GROUP, 3000, 4),
+				NewEntry(TAG_ACL_OTHER, math.MaxUint32, 5),
+			},
+			new: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+				NewEntry(TAG_ACL_USER, 2001, 6),
+				NewEntry(TAG_ACL_GROUP, 3000, 7),
+				NewEntry(TAG_ACL_MASK, math.MaxUint32, 7),
+				NewEntry(TAG_ACL_OTHER, math.MaxUint32, 0),
+			},
+			want: []string{
+				"- USER:2000:rw-",
+				"+ USER:2001:rw-",
+				"~ GROUP:3000:r-- -> rwx",
+				"+ MASK::rwx",
+				"~ OTHER::r-x -> ---",
+			},
+		},
+		{
+			name: "qualifier of owner entries ignored",
+			old:  []*ACLEntry{NewEntry(TAG_ACL_USER_OBJ, 1000, 7), NewEntry(TAG_ACL_GROUP_OBJ, 1000, 5)},
+			new:  []*ACLEntry{NewEntry(TAG_ACL_USER_OBJ, math.MaxUint32, 7), NewEntry(TAG_ACL_GROUP_OBJ, math.MaxUint32, 4)},
+			want: []string{"~ GROUP_OBJ::r-x -> r--"},
+		},
+		{
+			name: "qualifier of mask and other ignored",
+			old:  []*ACLEntry{NewEntry(TAG_ACL_USER, 1000, 7), NewEntry(TAG_ACL_MASK, 0, 7), NewEntry(TAG_ACL_OTHER, 0, 4)},
+			new:  []*ACLEntry{NewEntry(TAG_ACL_USER, 1001, 7), NewEntry(TAG_ACL_MASK, math.MaxUint32, 7), NewEntry(TAG_ACL_OTHER, math.MaxUint32, 4)},
+			want: []string{"- USER:1000:rwx", "+ USER:1001:rwx"},
+		},
+		{
+			name: "from empty",
+			old:  []*ACLEntry{},
+			new:  []*ACLEntry{NewEntry(TAG_ACL_GROUP, 3000, 5)},
+			want: []string{"+ GROUP:3000:r-x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := &ACL{version: 2, entries: tt.old}
+			new := &ACL{version: 2, entries: tt.new}
+			got := []string{}
+			for _, d := range old.Diff(new) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryDiff_JSON(t *testing.T) {
+	diffs := []EntryDiff{
+		{Kind: DiffAdded, Tag: TAG_ACL_USER, ID: 1000, NewPerm: 7},
+		{Kind: DiffRemoved, Tag: TAG_ACL_GROUP, ID: 2000, OldPerm: 5},
+		{Kind: DiffModified, Tag: TAG_ACL_OTHER, ID: math.MaxUint32, OldPerm: 5, NewPerm: 0},
+	}
+	b, err := json.Marshal(diffs)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `[{"kind":"added","tag":"USER","id":1000,"newPerm":"rwx"},{"kind":"removed","tag":"GROUP","id":2000,"oldPerm":"r-x"},{"kind":"modified","tag":"OTHER","id":4294967295,"oldPerm":"r-x","newPerm":"---"}]`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+	got := []EntryDiff{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, diffs) {
+		t.Errorf("round trip = %v, want %v", got, diffs)
+	}
+}
@@ -0,0 +1,132 @@
+package acls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DumpRecord holds an ACL attribute of a path in a Dump
+type DumpRecord struct {
+	// Path is relative to the root of the dump
+	Path string  `json:"path"`
+	Attr ACLAttr `json:"attr"`
+	ACL  *ACL    `json:"acl"`
+}
+
+// Dump is a snapshot of the ACLs of a tree. Directories without
+// a default ACL are recorded with an empty default ACL.
+type Dump struct {
+	Root    string        `json:"root"`
+	Records []*DumpRecord `json:"records"`
+
+	indexOnce sync.Once
+	index     map[pathAttr]*ACL
+}
+
+// DumpTree records the ACLs of the tree below root. opts.Attrs
+// defaults to both access and default ACLs. Paths that can not be
+// read are reported via the returned BulkResult.
+func DumpTree(ctx context.Context, root string, opts BulkOptions) (*Dump, *BulkResult, error) {
+	if len(opts.Attrs) == 0 {
+		opts.Attrs = []ACLAttr{PosixACLAccess, PosixACLDefault}
+	}
+	b := newBulkRun(opts)
+	mu := sync.Mutex{}
+	dump := &Dump{Root: root, Records: []*DumpRecord{}}
+
+	err := walkTree(ctx, root, opts.Workers, opts.RateLimit, func(fsPath string, d fs.DirEntry, err error) {
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		rel, err := filepath.Rel(root, fsPath)
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		records := []*DumpRecord{}
+		for _, attr := range b.attrsFor(d) {
+			a, err := loadForCompare(fsPath, attr, opts.Logger)
+			if err != nil {
+				b.record(fsPath, false, &BulkError{Path: fsPath, Attr: attr, Err: err})
+				return
+			}
+			records = append(records, &DumpRecord{Path: rel, Attr: attr, ACL: a})
+		}
+		mu.Lock()
+		dump.Records = append(dump.Records, records...)
+		mu.Unlock()
+		b.record(fsPath, false, nil)
+	})
+
+	sort.Slice(dump.Records, func(i, j int) bool {
+		if dump.Records[i].Path != dump.Records[j].Path {
+			return dump.Records[i].Path < dump.Records[j].Path
+		}
+		return dump.Records[i].Attr < dump.Records[j].Attr
+	})
+	return dump, b.finish(), err
+}
+
+// loadForCompare loads the attr of fsPath. A missing default
+// ACL is returned as empty ACL instead of being bootstrapped from
+// the file mode, so that its absence can be compared.
+func loadForCompare(fsPath string, attr ACLAttr, logger *slog.Logger) (*ACL, error) {
+	a := NewACL(WithLogger(logger))
+	exists, err := a.load(fsPath, attr)
+	if err != nil {
+		return nil, err
+	}
+	if attr == PosixACLDefault && !exists {
+		return NewACL(WithLogger(logger)), nil
+	}
+	return a, nil
+}
+
+// JSON renders the dump as indented JSON
+func (d *Dump) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// LoadDump decodes a dump previously rendered via JSON
+func LoadDump(b []byte) (*Dump, error) {
+	d := &Dump{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	for _, r := range d.Records {
+		if r.ACL == nil {
+			return nil, fmt.Errorf("dump record of %q %s lacks the ACL", r.Path, r.Attr)
+		}
+	}
+	return d, nil
+}
+
+// Get returns the recorded ACL of the given path relative to the
+// root of the dump, nil if there is none. Records must not be
+// modified after the first call.
+func (d *Dump) Get(relPath string, attr ACLAttr) *ACL {
+	d.indexOnce.Do(func() {
+		d.index = make(map[pathAttr]*ACL, len(d.Records))
+		for _, r := range d.Records {
+			d.index[pathAttr{path: r.Path, attr: r.Attr}] = r.ACL
+		}
+	})
+	return d.index[pathAttr{path: filepath.Clean(relPath), attr: attr}]
+}
+
+// Expected implements Baseline, the path is looked up relative to root
+func (d *Dump) Expected(root string, fsPath string, _ fs.DirEntry, attr ACLAttr, _ *ACL) (*ACL, bool, error) {
+	rel, err := filepath.Rel(root, fsPath)
+	if err != nil {
+		return nil, false, err
+	}
+	a := d.Get(rel, attr)
+	return a, a != nil, nil
+}
@@ -0,0 +1,51 @@
+package acls
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestDumpTree(t *testing.T) {
+	root := createTestTree(t, "a/file1", "file2")
+	addOnDisk(t, filepath.Join(root, "file2"), NewEntry(TAG_ACL_USER, 4711, PermRead))
+
+	dump, result, err := DumpTree(context.Background(), root, BulkOptions{})
+	if err != nil {
+		t.Fatalf("DumpTree() unexpected error %v", err)
+	}
+	if result.Scanned != 4 || result.Failed != 0 {
+		t.Errorf("unexpected counters %+v", result.BulkProgress)
+	}
+	// access ACLs of all 4 paths, default ACLs of the 2 directories
+	if len(dump.Records) != 6 {
+		t.Errorf("expected 6 records, got %d", len(dump.Records))
+	}
+
+	b, err := dump.JSON()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	loaded, err := LoadDump(b)
+	if err != nil {
+		t.Fatalf("LoadDump() unexpected error %v", err)
+	}
+
+	a := loaded.Get("file2", PosixACLAccess)
+	if a == nil || a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) == nil {
+		t.Errorf("expected recorded user entry for file2, got %v", a)
+	}
+	if a := loaded.Get("a", PosixACLDefault); a == nil || len(a.GetEntries()) != 0 {
+		t.Errorf("expected empty default ACL for directory a, got %v", a)
+	}
+	if a := loaded.Get("a/file1", PosixACLDefault); a != nil {
+		t.Errorf("expected no default ACL for a file, got %s", a.String())
+	}
+	if a := loaded.Get("./a/../a/file1", PosixACLAccess); a == nil {
+		t.Errorf("expected lookup of unclean path to succeed")
+	}
+
+	if _, err := LoadDump([]byte(`{"records":[{"path":"a","attr":"system.posix_acl_access"}]}`)); err == nil {
+		t.Errorf("expected error loading record without ACL")
+	}
+}
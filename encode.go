@@ -0,0 +1,99 @@
+package acls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// aclEntrySize is the size of an entry in the version 2 xattr format
+const aclEntrySize = 8
+
+// AppendBinary appends the ACLEntry in the little endian xattr
+// format to dst and returns the extended buffer
+func (a *ACLEntry) AppendBinary(dst []byte) []byte {
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(a.tag))
+	dst = binary.LittleEndian.AppendUint16(dst, a.perm)
+	return binary.LittleEndian.AppendUint32(dst, a.id)
+}
+
+// AppendBinary appends the ACL in its xattr representation, as written
+// by ToByteSlice, to dst and returns the extended buffer. It does not
+// allocate if dst has enough capacity and the entries are sorted, as
+// they are after loading or parsing. An *UnsupportedVersionError is
+// returned, and dst left unchanged, if no ACLCodec is registered for
+// the version of the ACL.
+func (a *ACL) AppendBinary(dst []byte) ([]byte, error) {
+	c, err := LookupACLCodec(a.version)
+	if err != nil {
+		return dst, err
+	}
+	dst = binary.LittleEndian.AppendUint32(dst, a.version)
+	entries := a.entries
+	if !slices.IsSortedFunc(entries, compareEntries) {
+		entries = a.sortedEntries()
+	}
+	if _, ok := c.(posixACLCodec); ok {
+		for _, e := range entries {
+			dst = e.AppendBinary(dst)
+		}
+		return dst, nil
+	}
+	buf := bytes.NewBuffer(dst)
+	c.EncodeEntries(buf, entries)
+	return buf.Bytes(), nil
+}
+
+// compareEntries orders entries like entryLess
+func compareEntries(a, b *ACLEntry) int {
+	switch {
+	case entryLess(a, b):
+		return -1
+	case entryLess(b, a):
+		return 1
+	}
+	return 0
+}
+
+// DecodeEntryValues decodes the xattr representation of an ACL,
+// appending its entries to dst, and returns the extended slice along
+// with the version. Unlike loading into an ACL it does not allocate if
+// dst has enough capacity, so a slice can be reused across many ACLs.
+// Versions other than POSIX_ACL_XATTR_VERSION are decoded by their
+// registered ACLCodec, an *UnsupportedVersionError is returned if
+// there is none.
+func DecodeEntryValues(dst []ACLEntry, b []byte) ([]ACLEntry, uint32, error) {
+	if len(b) < 4 {
+		return dst, 0, fmt.Errorf("expecting at least a 32 bit header, got %d", len(b)*4)
+	}
+	version := binary.LittleEndian.Uint32(b[:4])
+	c, err := LookupACLCodec(version)
+	if err != nil {
+		return dst, version, err
+	}
+	if _, ok := c.(posixACLCodec); !ok {
+		entries, err := c.DecodeEntries(b[4:])
+		if err != nil {
+			return dst, version, err
+		}
+		for _, e := range entries {
+			dst = append(dst, *e)
+		}
+		return dst, version, nil
+	}
+
+	b = b[4:]
+	if len(b)%aclEntrySize != 0 {
+		return dst, version, fmt.Errorf("malformed data")
+	}
+	dst = slices.Grow(dst, len(b)/aclEntrySize)
+	for ; len(b) > 0; b = b[aclEntrySize:] {
+		dst = append(dst, ACLEntry{
+			tag:  Tag(binary.LittleEndian.Uint16(b[:2])),
+			perm: binary.LittleEndian.Uint16(b[2:4]),
+			id:   binary.LittleEndian.Uint32(b[4:8]),
+		})
+	}
+	return dst, version, nil
+}
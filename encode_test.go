@@ -0,0 +1,167 @@
+package acls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// encodeTestHex is an ACL with named user and group entries
+const encodeTestHex = "0200000001000700ffffffff02000600e803000004000500ffffffff08000700b615000010000700ffffffff20000500ffffffff"
+
+func encodeTestACL(t testing.TB) (*ACL, []byte) {
+	t.Helper()
+	b, err := hex.DecodeString(encodeTestHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewACL()
+	if err := a.parse(b); err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestACL_AppendBinary(t *testing.T) {
+	a, b := encodeTestACL(t)
+	if got, err := a.AppendBinary(nil); err != nil || !bytes.Equal(got, b) {
+		t.Errorf("AppendBinary() = %x, %v, want %x", got, err, b)
+	}
+	prefix := []byte("xx")
+	if got, _ := a.AppendBinary(prefix); !bytes.Equal(got[:2], prefix) || !bytes.Equal(got[2:], b) {
+		t.Errorf("AppendBinary() with prefix = %x", got)
+	}
+
+	// unsorted entries are encoded sorted without modifying the ACL
+	unsorted := NewACL()
+	for i := len(a.entries) - 1; i >= 0; i-- {
+		unsorted.entries = append(unsorted.entries, a.entries[i])
+	}
+	if got, _ := unsorted.AppendBinary(nil); !bytes.Equal(got, b) {
+		t.Errorf("AppendBinary() of unsorted ACL = %x, want %x", got, b)
+	}
+	if unsorted.entries[0].tag != TAG_ACL_OTHER {
+		t.Error("AppendBinary() sorted the ACL in place")
+	}
+
+	buf := make([]byte, 0, 64)
+	if allocs := testing.AllocsPerRun(100, func() { buf, _ = a.AppendBinary(buf[:0]) }); allocs != 0 {
+		t.Errorf("AppendBinary() allocates %v times", allocs)
+	}
+
+	// versions without codec are rejected instead of written as version 2
+	unknown := NewACL(WithVersion(1))
+	unknown.entries = a.entries
+	var verr *UnsupportedVersionError
+	if got, err := unknown.AppendBinary(prefix); !errors.As(err, &verr) || !bytes.Equal(got, prefix) {
+		t.Errorf("AppendBinary() of version 1 = %x, %v, want UnsupportedVersionError", got, err)
+	}
+	result := &bytes.Buffer{}
+	if err := unknown.ToByteSlice(result); !errors.As(err, &verr) || result.Len() != 0 {
+		t.Errorf("ToByteSlice() of version 1 wrote %d bytes, error = %v", result.Len(), err)
+	}
+}
+
+func TestDecodeEntryValues(t *testing.T) {
+	a, b := encodeTestACL(t)
+	entries, version, err := DecodeEntryValues(nil, b)
+	if err != nil {
+		t.Fatalf("DecodeEntryValues() error = %v", err)
+	}
+	if version != POSIX_ACL_XATTR_VERSION || len(entries) != len(a.entries) {
+		t.Fatalf("DecodeEntryValues() = version %d, %d entries", version, len(entries))
+	}
+	for i := range entries {
+		if !entries[i].Equal(a.entries[i]) {
+			t.Errorf("entry %d = %s, want %s", i, &entries[i], a.entries[i])
+		}
+	}
+
+	dst := make([]ACLEntry, 0, 8)
+	if allocs := testing.AllocsPerRun(100, func() { dst, _, _ = DecodeEntryValues(dst[:0], b) }); allocs != 0 {
+		t.Errorf("DecodeEntryValues() allocates %v times", allocs)
+	}
+
+	for _, tc := range []struct {
+		name string
+		hex  string
+	}{
+		{"short header", "0200"},
+		{"short entry", "0200000001000700ff"},
+		{"unsupported version", "0100000001000700ffffffff"},
+	} {
+		in, _ := hex.DecodeString(tc.hex)
+		if _, _, err := DecodeEntryValues(nil, in); err == nil {
+			t.Errorf("%s: DecodeEntryValues() succeeded", tc.name)
+		}
+	}
+	in, _ := hex.DecodeString("0100000001000700ffffffff")
+	var verr *UnsupportedVersionError
+	if _, _, err := DecodeEntryValues(nil, in); !errors.As(err, &verr) {
+		t.Errorf("DecodeEntryValues() error = %v, want UnsupportedVersionError", err)
+	}
+}
+
+// writeReflect encodes like ToByteSlice before AppendBinary existed
+func writeReflect(result *bytes.Buffer, a *ACL) {
+	binary.Write(result, binary.LittleEndian, a.version)
+	for _, e := range a.sortedEntries() {
+		binary.Write(result, binary.LittleEndian, e.tag)
+		binary.Write(result, binary.LittleEndian, e.perm)
+		binary.Write(result, binary.LittleEndian, e.id)
+	}
+}
+
+func BenchmarkToByteSlice(b *testing.B) {
+	a, _ := encodeTestACL(b)
+	buf := &bytes.Buffer{}
+	b.ReportAllocs()
+	for b.Loop() {
+		buf.Reset()
+		a.ToByteSlice(buf)
+	}
+}
+
+func BenchmarkToByteSliceReflect(b *testing.B) {
+	a, _ := encodeTestACL(b)
+	buf := &bytes.Buffer{}
+	b.ReportAllocs()
+	for b.Loop() {
+		buf.Reset()
+		writeReflect(buf, a)
+	}
+}
+
+func BenchmarkAppendBinary(b *testing.B) {
+	a, _ := encodeTestACL(b)
+	buf := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf, _ = a.AppendBinary(buf[:0])
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	_, data := encodeTestACL(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		a := &ACL{}
+		if err := a.parse(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeEntryValues(b *testing.B) {
+	_, data := encodeTestACL(b)
+	dst := make([]ACLEntry, 0, 8)
+	b.ReportAllocs()
+	for b.Loop() {
+		var err error
+		if dst, _, err = DecodeEntryValues(dst[:0], data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package acls
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// EntrySpec is an ACL entry in the text form used by setfacl,
+// e.g. "u::rwx", "g:analysts:r-X" or "o::---".
+// Other than ACLEntry it can express the conditional execute
+// permission "X".
+type EntrySpec struct {
+	Tag  Tag
+	ID   uint32
+	Perm uint16
+	// CondExecute is set for the "X" permission, granting execute
+	// only to directories or if any of the owner, owning group or
+	// other entries already grants execute
+	CondExecute bool
+}
+
+// ParseEntrySpec parses an entry in the form "type:qualifier:perm".
+// type is one of u[ser], g[roup], m[ask] or o[ther]. The qualifier
+// is a numeric ID or a user or group name, it is empty for the
+// owner, owning group, mask and other entries. perm consists of
+// the letters r, w, x, X and -.
+func ParseEntrySpec(s string) (*EntrySpec, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid entry %q, expected type:qualifier:perm", s)
+	}
+	result := &EntrySpec{ID: ACL_UNDEFINED_ID}
+
+	qualified := parts[1] != ""
+	switch parts[0] {
+	case "u", "user":
+		result.Tag = TAG_ACL_USER_OBJ
+		if qualified {
+			result.Tag = TAG_ACL_USER
+		}
+	case "g", "group":
+		result.Tag = TAG_ACL_GROUP_OBJ
+		if qualified {
+			result.Tag = TAG_ACL_GROUP
+		}
+	case "m", "mask":
+		result.Tag = TAG_ACL_MASK
+	case "o", "other":
+		result.Tag = TAG_ACL_OTHER
+	default:
+		return nil, fmt.Errorf("invalid entry %q, unknown type %q", s, parts[0])
+	}
+	if qualified && result.Tag != TAG_ACL_USER && result.Tag != TAG_ACL_GROUP {
+		return nil, fmt.Errorf("invalid entry %q, %s entries take no qualifier", s, Tag2String(result.Tag))
+	}
+
+	if qualified {
+		id, err := lookupID(result.Tag, parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry %q: %w", s, err)
+		}
+		result.ID = id
+	}
+
+	for _, c := range parts[2] {
+		switch c {
+		case 'r':
+			result.Perm |= PermRead
+		case 'w':
+			result.Perm |= PermWrite
+		case 'x':
+			result.Perm |= PermExecute
+		case 'X':
+			result.CondExecute = true
+		case '-':
+		default:
+			return nil, fmt.Errorf("invalid entry %q, unknown permission %q", s, c)
+		}
+	}
+	return result, nil
+}
+
+// lookupID resolves a numeric ID or user respectively group name
+func lookupID(tag Tag, qualifier string) (uint32, error) {
+	if id, err := strconv.ParseUint(qualifier, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	var idStr string
+	if tag == TAG_ACL_USER {
+		u, err := user.Lookup(qualifier)
+		if err != nil {
+			return 0, err
+		}
+		idStr = u.Uid
+	} else {
+		g, err := user.LookupGroup(qualifier)
+		if err != nil {
+			return 0, err
+		}
+		idStr = g.Gid
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(id), nil
+}
+
+// Entry returns the ACLEntry for a path, resolving the "X" permission.
+// isDir reports whether the path is a directory, current is the ACL
+// the conditional execute permission is evaluated against.
+func (s *EntrySpec) Entry(isDir bool, current *ACL) *ACLEntry {
+	perm := s.Perm
+	if s.CondExecute && (isDir || current.objExecutable()) {
+		perm |= PermExecute
+	}
+	return NewEntry(s.Tag, s.ID, perm)
+}
+
+// String returns the entry in its short text form
+func (s *EntrySpec) String() string {
+	qualifier := ""
+	if s.ID != ACL_UNDEFINED_ID {
+		qualifier = strconv.FormatUint(uint64(s.ID), 10)
+	}
+	perm := []byte(PermUintToString(s.Perm))
+	if s.CondExecute && perm[2] == '-' {
+		perm[2] = 'X'
+	}
+	prefix := "o"
+	switch s.Tag {
+	case TAG_ACL_USER_OBJ, TAG_ACL_USER:
+		prefix = "u"
+	case TAG_ACL_GROUP_OBJ, TAG_ACL_GROUP:
+		prefix = "g"
+	case TAG_ACL_MASK:
+		prefix = "m"
+	}
+	return fmt.Sprintf("%s:%s:%s", prefix, qualifier, perm)
+}
+
+// objExecutable reports whether the owner, owning group or
+// other entry grants execute, which is what the file mode shows
+func (a *ACL) objExecutable() bool {
+	for _, e := range a.entries {
+		switch e.tag {
+		case TAG_ACL_USER_OBJ, TAG_ACL_GROUP_OBJ, TAG_ACL_OTHER:
+			if e.HasPerm(PermExecute) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package acls
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseEntrySpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    *EntrySpec
+		wantErr bool
+	}{
+		{name: "owner", input: "u::rwx", want: &EntrySpec{Tag: TAG_ACL_USER_OBJ, ID: ACL_UNDEFINED_ID, Perm: PermAll}},
+		{name: "named user", input: "user:1000:rw-", want: &EntrySpec{Tag: TAG_ACL_USER, ID: 1000, Perm: PermRead | PermWrite}},
+		{name: "named user by name", input: "u:root:r", want: &EntrySpec{Tag: TAG_ACL_USER, ID: 0, Perm: PermRead}},
+		{name: "owning group", input: "g::r-x", want: &EntrySpec{Tag: TAG_ACL_GROUP_OBJ, ID: ACL_UNDEFINED_ID, Perm: PermRead | PermExecute}},
+		{name: "named group conditional execute", input: "g:5558:r-X", want: &EntrySpec{Tag: TAG_ACL_GROUP, ID: 5558, Perm: PermRead, CondExecute: true}},
+		{name: "named group by name", input: "group:root:---", want: &EntrySpec{Tag: TAG_ACL_GROUP, ID: 0}},
+		{name: "mask", input: "m::rwx", want: &EntrySpec{Tag: TAG_ACL_MASK, ID: ACL_UNDEFINED_ID, Perm: PermAll}},
+		{name: "other", input: " o::--- ", want: &EntrySpec{Tag: TAG_ACL_OTHER, ID: ACL_UNDEFINED_ID}},
+		{name: "missing perm", input: "u:1000", wantErr: true},
+		{name: "unknown type", input: "x:1000:rwx", wantErr: true},
+		{name: "qualified mask", input: "m:1000:rwx", wantErr: true},
+		{name: "unknown user", input: "u:no-such-user-hopefully:rwx", wantErr: true},
+		{name: "invalid perm", input: "u::rwz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEntrySpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEntrySpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *got != *tt.want {
+				t.Errorf("ParseEntrySpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntrySpec_Entry(t *testing.T) {
+	noExec := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, 1000, PermRead|PermWrite),
+		NewEntry(TAG_ACL_GROUP_OBJ, 1000, PermRead),
+		NewEntry(TAG_ACL_OTHER, math.MaxUint32, PermNone),
+	}}
+	exec := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, 1000, PermAll),
+		NewEntry(TAG_ACL_OTHER, math.MaxUint32, PermNone),
+	}}
+	spec, err := ParseEntrySpec("g:5558:r-X")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tests := []struct {
+		name    string
+		isDir   bool
+		current *ACL
+		want    uint16
+	}{
+		{name: "file without execute", isDir: false, current: noExec, want: PermRead},
+		{name: "directory", isDir: true, current: noExec, want: PermRead | PermExecute},
+		{name: "executable file", isDir: false, current: exec, want: PermRead | PermExecute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spec.Entry(tt.isDir, tt.current); !got.Equal(NewEntry(TAG_ACL_GROUP, 5558, tt.want)) {
+				t.Errorf("Entry() = %s, want perm %d", got.String(), tt.want)
+			}
+		})
+	}
+	if got := spec.String(); got != "g:5558:r-X" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package acls
+
+import "slices"
+
+// Credential is the identity of a process accessing a file
+type Credential struct {
+	UID uint32
+	// GIDs holds the effective and all supplementary group IDs
+	GIDs []uint32
+}
+
+// Allowed reports whether the ACL grants all requested permission bits
+// to the credential, following the POSIX.1e access check algorithm as
+// implemented by Linux. owner and group are the UID and GID of the
+// file. Capabilities, like those of root, are not considered.
+func (a *ACL) Allowed(owner, group uint32, cred Credential, want uint16) bool {
+	return newACLEvaluator(a).allowed(owner, group, cred, want)
+}
+
+// aclEvaluator evaluates access checks against an ACL whose entries
+// are sorted once, for callers checking many credentials
+type aclEvaluator struct {
+	entries []*ACLEntry
+	mask    *ACLEntry
+}
+
+// newACLEvaluator returns an evaluator of the current entries of a
+func newACLEvaluator(a *ACL) *aclEvaluator {
+	ev := &aclEvaluator{entries: a.sortedEntries()}
+	for _, e := range ev.entries {
+		if e.tag == TAG_ACL_MASK {
+			ev.mask = e
+		}
+	}
+	return ev
+}
+
+// allowed implements Allowed
+func (ev *aclEvaluator) allowed(owner, group uint32, cred Credential, want uint16) bool {
+	// masked applies the mask to entries of the group class
+	masked := func(e *ACLEntry) bool {
+		perm := e.perm
+		if ev.mask != nil {
+			perm &= ev.mask.perm
+		}
+		return perm&want == want
+	}
+
+	groupMatched := false
+	for _, e := range ev.entries {
+		switch e.tag {
+		case TAG_ACL_USER_OBJ:
+			if cred.UID == owner {
+				return e.perm&want == want
+			}
+		case TAG_ACL_USER:
+			if cred.UID == e.id {
+				return masked(e)
+			}
+		case TAG_ACL_GROUP_OBJ:
+			if slices.Contains(cred.GIDs, group) {
+				groupMatched = true
+				if e.perm&want == want {
+					return masked(e)
+				}
+			}
+		case TAG_ACL_GROUP:
+			if slices.Contains(cred.GIDs, e.id) {
+				groupMatched = true
+				if e.perm&want == want {
+					return masked(e)
+				}
+			}
+		case TAG_ACL_OTHER:
+			if groupMatched {
+				return false
+			}
+			return e.perm&want == want
+		}
+	}
+	return false
+}
+
+// EffectivePerm returns the permission bits the ACL grants to the
+// credential, each bit checked on its own via Allowed. Note that
+// a combination of bits granted by different group entries is not
+// granted when requested at once.
+func (a *ACL) EffectivePerm(owner, group uint32, cred Credential) uint16 {
+	ev := newACLEvaluator(a)
+	var result uint16
+	for _, bit := range []uint16{PermRead, PermWrite, PermExecute} {
+		if ev.allowed(owner, group, cred, bit) {
+			result |= bit
+		}
+	}
+	return result
+}
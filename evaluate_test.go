@@ -0,0 +1,59 @@
+package acls
+
+import (
+	"math"
+	"testing"
+)
+
+func TestACL_Allowed(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	a := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 6),
+		NewEntry(TAG_ACL_USER, 2000, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+		NewEntry(TAG_ACL_GROUP, 3000, 2),
+		NewEntry(TAG_ACL_MASK, u, 6),
+		NewEntry(TAG_ACL_OTHER, u, 1),
+	}}
+	tests := []struct {
+		name string
+		cred Credential
+		want uint16
+		ok   bool
+	}{
+		{name: "owner not masked", cred: Credential{UID: 1000}, want: PermRead | PermWrite, ok: true},
+		{name: "owner beyond entry", cred: Credential{UID: 1000}, want: PermExecute, ok: false},
+		{name: "owner wins over group", cred: Credential{UID: 1000, GIDs: []uint32{3000}}, want: PermExecute, ok: false},
+		{name: "named user masked", cred: Credential{UID: 2000}, want: PermExecute, ok: false},
+		{name: "named user within mask", cred: Credential{UID: 2000}, want: PermRead | PermWrite, ok: true},
+		{name: "owning group", cred: Credential{UID: 5, GIDs: []uint32{100}}, want: PermRead, ok: true},
+		{name: "group match denies other", cred: Credential{UID: 5, GIDs: []uint32{100}}, want: PermExecute, ok: false},
+		{name: "named group", cred: Credential{UID: 5, GIDs: []uint32{3000}}, want: PermWrite, ok: true},
+		{name: "bits of different groups not combined", cred: Credential{UID: 5, GIDs: []uint32{100, 3000}}, want: PermRead | PermWrite, ok: false},
+		{name: "other", cred: Credential{UID: 5, GIDs: []uint32{7}}, want: PermExecute, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.Allowed(1000, 100, tt.cred, tt.want); got != tt.ok {
+				t.Errorf("Allowed() = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestACL_EffectivePerm(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	a := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+		NewEntry(TAG_ACL_GROUP, 3000, 6),
+		NewEntry(TAG_ACL_MASK, u, 6),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	}}
+	if got := a.EffectivePerm(1000, 100, Credential{UID: 5, GIDs: []uint32{100, 3000}}); got != 6 {
+		t.Errorf("EffectivePerm() = %s, want rw-", PermUintToString(got))
+	}
+	if got := a.EffectivePerm(1000, 100, Credential{UID: 1000}); got != 7 {
+		t.Errorf("EffectivePerm() = %s, want rwx", PermUintToString(got))
+	}
+}
@@ -2,7 +2,4 @@ module github.com/steiler/acls
 
 go 1.25.0
 
-require (
-	github.com/sirupsen/logrus v1.10.0
-	golang.org/x/sys v0.47.0
-)
+require golang.org/x/sys v0.47.0
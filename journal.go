@@ -0,0 +1,156 @@
+package acls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// JournalRecord holds the state of an ACL attribute of a path
+// before it got modified
+type JournalRecord struct {
+	Path string  `json:"path"`
+	Attr ACLAttr `json:"attr"`
+	// Existed reports whether the attribute existed, or the ACL
+	// was bootstrapped from the file mode
+	Existed bool `json:"existed"`
+	Before  *ACL `json:"before"`
+}
+
+// Journal records the previous state of ACLs before they are
+// modified, so the modification can be rolled back. Records are
+// stored as JSON lines and synced to disk before Record returns.
+// A Journal is safe for concurrent use.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenJournal opens the journal file at the given path for appending,
+// creating it if it does not exist
+func OpenJournal(journalPath string) (*Journal, error) {
+	f, err := os.OpenFile(journalPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: f}, nil
+}
+
+// Record appends the state of the given attr of fsPath
+// to the journal and syncs it to disk
+func (j *Journal) Record(fsPath string, attr ACLAttr, before *ACL, existed bool) error {
+	b, err := json.Marshal(&JournalRecord{Path: fsPath, Attr: attr, Existed: existed, Before: before})
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(b); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// ReadJournal returns the records of the journal file in the order
+// they were written. An incomplete last line, as left by a crash
+// while writing, is ignored.
+func ReadJournal(journalPath string) ([]*JournalRecord, error) {
+	b, err := os.ReadFile(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	lines := bytes.Split(b, []byte("\n"))
+	// the last element is either empty or an incomplete
+	// record that never got completely written
+	lines = lines[:len(lines)-1]
+
+	result := make([]*JournalRecord, 0, len(lines))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		r := &JournalRecord{}
+		if err := json.Unmarshal(line, r); err != nil {
+			return nil, fmt.Errorf("journal %q line %d: %w", journalPath, i+1, err)
+		}
+		if r.Before == nil {
+			return nil, fmt.Errorf("journal %q line %d: record lacks the previous ACL", journalPath, i+1)
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Rollback restores the ACLs recorded in the journal file by replaying it
+// in reverse order, so every path ends up with the state recorded first.
+// Attributes that did not exist are removed again, for access ACLs this
+// is done by applying the minimal ACL matching the file mode.
+// RateLimit, Progress and Logger of opts are honored.
+func Rollback(ctx context.Context, journalPath string, opts BulkOptions) (*BulkResult, error) {
+	records, err := ReadJournal(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	b := newBulkRun(opts)
+
+	tick, stopTick := rateTicker(opts.RateLimit)
+	defer stopTick()
+
+	for i := len(records) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return b.finish(), err
+		}
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return b.finish(), ctx.Err()
+			}
+		}
+		r := records[i]
+		if err := restore(r, opts); err != nil {
+			b.record(r.Path, false, &BulkError{Path: r.Path, Attr: r.Attr, Err: err})
+			continue
+		}
+		b.record(r.Path, true, nil)
+	}
+	return b.finish(), nil
+}
+
+// restore brings the attr of a path back into the recorded state
+func restore(r *JournalRecord, opts BulkOptions) error {
+	a := r.Before.Clone()
+	a.SetLogger(opts.Logger)
+	switch {
+	case r.Existed:
+		return a.Apply(r.Path, r.Attr)
+	case r.Attr == PosixACLDefault:
+		err := unix.Removexattr(r.Path, string(r.Attr))
+		if err == unix.ENODATA {
+			return nil
+		}
+		return err
+	}
+	// a minimal ACL is stored by the kernel as file mode only
+	a.DeleteEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0))
+	for _, e := range a.GetEntries() {
+		if e.tag == TAG_ACL_USER || e.tag == TAG_ACL_GROUP {
+			a.DeleteEntry(e)
+		}
+	}
+	return a.Apply(r.Path, r.Attr)
+}
@@ -0,0 +1,114 @@
+package acls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestJournal_Rollback(t *testing.T) {
+	root := createTestTree(t, "a/file1", "file2")
+	withACL := filepath.Join(root, "file2")
+	addOnDisk(t, withACL, NewEntry(TAG_ACL_GROUP, 1234, PermRead))
+
+	before := map[string]*ACL{}
+	for _, p := range []string{root, filepath.Join(root, "a"), filepath.Join(root, "a", "file1"), withACL} {
+		a := NewACL()
+		if err := a.Load(p, PosixACLAccess); err != nil {
+			t.Fatalf("failed loading ACL %v", err)
+		}
+		before[p] = a
+	}
+
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	j, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal() unexpected error %v", err)
+	}
+	opts := BulkOptions{Journal: j, Attrs: []ACLAttr{PosixACLAccess, PosixACLDefault}}
+	if _, err := BulkApply(context.Background(), root, addUserTransform(4711), opts); err != nil {
+		t.Fatalf("BulkApply() unexpected error %v", err)
+	}
+	// a second modification on top must be rolled back as well
+	if _, err := BulkApply(context.Background(), root, addUserTransform(4712), opts); err != nil {
+		t.Fatalf("BulkApply() unexpected error %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close() unexpected error %v", err)
+	}
+
+	records, err := ReadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("ReadJournal() unexpected error %v", err)
+	}
+	// 4 access ACLs and 2 default ACLs per run
+	if len(records) != 12 {
+		t.Errorf("expected 12 journal records, got %d", len(records))
+	}
+
+	result, err := Rollback(context.Background(), journalPath, BulkOptions{})
+	if err != nil {
+		t.Fatalf("Rollback() unexpected error %v", err)
+	}
+	if result.Failed != 0 {
+		t.Fatalf("Rollback() failed paths: %v", result.Errors)
+	}
+
+	for p, want := range before {
+		a := NewACL()
+		if err := a.Load(p, PosixACLAccess); err != nil {
+			t.Fatalf("failed loading ACL %v", err)
+		}
+		if !a.Equal(want) {
+			t.Errorf("%q not restored, got %s, want %s", p, a.String(), want.String())
+		}
+		if p == withACL {
+			continue
+		}
+		if _, err := unix.Getxattr(p, string(PosixACLAccess), nil); err != unix.ENODATA {
+			t.Errorf("expected access ACL of %q to be removed, got %v", p, err)
+		}
+		if _, err := unix.Getxattr(p, string(PosixACLDefault), nil); err != unix.ENODATA {
+			t.Errorf("expected default ACL of %q to be removed, got %v", p, err)
+		}
+	}
+}
+
+func TestReadJournal_IncompleteRecord(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	j, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal() unexpected error %v", err)
+	}
+	if err := j.Record("/tmp/foo", PosixACLAccess, NewACL(), true); err != nil {
+		t.Fatalf("Record() unexpected error %v", err)
+	}
+	j.Close()
+
+	// simulate a crash while writing the second record
+	f, err := os.OpenFile(journalPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed opening journal %v", err)
+	}
+	f.WriteString(`{"path":"/tmp/bar","attr":"sys`)
+	f.Close()
+
+	records, err := ReadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("ReadJournal() unexpected error %v", err)
+	}
+	if len(records) != 1 || records[0].Path != "/tmp/foo" {
+		t.Errorf("expected only the complete record, got %v", records)
+	}
+
+	// a corrupt complete line is an error
+	if err := os.WriteFile(journalPath, []byte("{\n"), 0o600); err != nil {
+		t.Fatalf("failed writing journal %v", err)
+	}
+	if _, err := ReadJournal(journalPath); err == nil {
+		t.Errorf("expected error for corrupt journal")
+	}
+}
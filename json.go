@@ -0,0 +1,94 @@
+package acls
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// aclEntryJSON is the JSON representation of an ACLEntry,
+// a missing id decodes as ACL_UNDEFINED_ID
+type aclEntryJSON struct {
+	Tag  string  `json:"tag"`
+	ID   *uint32 `json:"id"`
+	Perm string  `json:"perm"`
+}
+
+// aclJSON is the JSON representation of an ACL
+type aclJSON struct {
+	Version uint32      `json:"version"`
+	Entries []*ACLEntry `json:"entries"`
+}
+
+// MarshalJSON encodes the ACLEntry as
+// {"tag": "USER", "id": 1000, "perm": "rwx"}
+// Tags String2Tag can not read back are rejected.
+func (a *ACLEntry) MarshalJSON() ([]byte, error) {
+	tag := Tag2String(a.tag)
+	if t, err := String2Tag(tag); err != nil || t != a.tag {
+		return nil, fmt.Errorf("unsupported tag 0x%x", uint16(a.tag))
+	}
+	return json.Marshal(aclEntryJSON{
+		Tag:  tag,
+		ID:   &a.id,
+		Perm: PermUintToString(a.perm),
+	})
+}
+
+// UnmarshalJSON decodes the ACLEntry from the
+// format produced by MarshalJSON
+func (a *ACLEntry) UnmarshalJSON(b []byte) error {
+	j := aclEntryJSON{}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	tag, err := String2Tag(j.Tag)
+	if err != nil {
+		return err
+	}
+	perm, err := PermStringToUint(j.Perm)
+	if err != nil {
+		return err
+	}
+	id := uint32(ACL_UNDEFINED_ID)
+	if j.ID != nil {
+		id = *j.ID
+	}
+	*a = ACLEntry{tag: tag, id: id, perm: perm}
+	return nil
+}
+
+// MarshalJSON encodes the ACL with its entries sorted
+func (a *ACL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(aclJSON{
+		Version: a.version,
+		Entries: a.sortedEntries(),
+	})
+}
+
+// UnmarshalJSON decodes the ACL from the
+// format produced by MarshalJSON. A missing version defaults to
+// POSIX_ACL_XATTR_VERSION, unsupported versions are rejected
+// with an *UnsupportedVersionError, as are null entries.
+func (a *ACL) UnmarshalJSON(b []byte) error {
+	j := aclJSON{}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.Version == 0 {
+		j.Version = POSIX_ACL_XATTR_VERSION
+	}
+	if _, err := LookupACLCodec(j.Version); err != nil {
+		return err
+	}
+	for i, e := range j.Entries {
+		if e == nil {
+			return fmt.Errorf("acl entry %d is null", i)
+		}
+	}
+	a.version = j.Version
+	a.entries = j.Entries
+	if a.entries == nil {
+		a.entries = []*ACLEntry{}
+	}
+	return nil
+}
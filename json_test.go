@@ -0,0 +1,91 @@
+package acls
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestACL_JSON(t *testing.T) {
+	a := &ACL{
+		version: 2,
+		entries: []*ACLEntry{
+			NewEntry(TAG_ACL_OTHER, math.MaxUint32, 5),
+			NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+			NewEntry(TAG_ACL_GROUP, 5558, 6),
+		},
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `{"version":2,"entries":[{"tag":"USER_OBJ","id":1000,"perm":"rwx"},{"tag":"GROUP","id":5558,"perm":"rw-"},{"tag":"OTHER","id":4294967295,"perm":"r-x"}]}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+
+	got := &ACL{}
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !got.Equal(a) {
+		t.Errorf("round trip = %s, want %s", got.String(), a.String())
+	}
+}
+
+func TestACLEntry_JSON_missingID(t *testing.T) {
+	e := &ACLEntry{}
+	if err := json.Unmarshal([]byte(`{"tag":"MASK","perm":"rwx"}`), e); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 7); !e.Equal(want) {
+		t.Errorf("json.Unmarshal() = %s, want %s", e, want)
+	}
+	if err := json.Unmarshal([]byte(`{"tag":"USER","id":0,"perm":"r--"}`), e); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := NewEntry(TAG_ACL_USER, 0, 4); !e.Equal(want) {
+		t.Errorf("json.Unmarshal() = %s, want %s", e, want)
+	}
+}
+
+func TestACLEntry_MarshalJSON_Errors(t *testing.T) {
+	tests := []*ACLEntry{
+		NewEntry(TAG_ACL_UNDEFINED_FIELD, 1, 7),
+		NewEntry(0x30, ACL_UNDEFINED_ID, 7),
+		NewEntry(0x80, 1, 7),
+	}
+	for _, e := range tests {
+		if b, err := json.Marshal(e); err == nil {
+			t.Errorf("expected error encoding tag 0x%x, got %s", uint16(e.tag), b)
+		}
+	}
+}
+
+func TestACLEntry_UnmarshalJSON_Errors(t *testing.T) {
+	tests := []string{
+		`{"tag":"FOO","id":1,"perm":"rwx"}`,
+		`{"tag":"USER","id":1,"perm":"rw"}`,
+		`[]`,
+	}
+	for _, s := range tests {
+		e := &ACLEntry{}
+		if err := json.Unmarshal([]byte(s), e); err == nil {
+			t.Errorf("expected error decoding %s", s)
+		}
+	}
+}
+
+func TestACL_UnmarshalJSON_Errors(t *testing.T) {
+	tests := []string{
+		`{"version":2,"entries":[null]}`,
+		`{"version":2,"entries":[{"tag":"USER_OBJ","id":4294967295,"perm":"rwx"},null]}`,
+		`{"version":2,"entries":{}}`,
+	}
+	for _, s := range tests {
+		a := NewACL()
+		if err := json.Unmarshal([]byte(s), a); err == nil {
+			t.Errorf("expected error decoding %s, got %v", s, a)
+		}
+	}
+}
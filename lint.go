@@ -0,0 +1,354 @@
+package acls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Severity classifies lint findings
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+// String returns the name of the Severity
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// MarshalJSON encodes the Severity by its name
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// LintTarget is the subject of a lint check
+type LintTarget struct {
+	Path string
+	// Info is the file metadata of Path
+	Info fs.FileInfo
+	// Access is the access ACL. For paths without ACL it holds the
+	// entries of the file mode, without MASK entry.
+	Access *ACL
+	// Default is the default ACL, nil if the path has none
+	Default *ACL
+}
+
+// LintFinding is a risky configuration found by a LintRule
+type LintFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	// Attr and Entry are empty if the finding does not refer to an entry
+	Attr    ACLAttr   `json:"attr,omitempty"`
+	Entry   *ACLEntry `json:"entry,omitempty"`
+	Message string    `json:"message"`
+}
+
+// LintRule checks a LintTarget for a risky configuration
+type LintRule interface {
+	// Name is the identifier of the rule used in the findings
+	Name() string
+	// Check returns the findings of the rule for the target
+	Check(t *LintTarget) ([]*LintFinding, error)
+}
+
+// Linter runs LintRules against paths
+type Linter struct {
+	Rules []LintRule
+}
+
+// NewLinter returns a Linter with the given rules,
+// if none are given the DefaultLintRules are used
+func NewLinter(rules ...LintRule) *Linter {
+	if len(rules) == 0 {
+		rules = DefaultLintRules(OSResolver{})
+	}
+	return &Linter{Rules: rules}
+}
+
+// DefaultLintRules returns the built-in rules. The rule checking for
+// unknown principals is only included if resolver is not nil.
+func DefaultLintRules(resolver IDResolver) []LintRule {
+	rules := []LintRule{
+		otherWritableRule{},
+		deadEntryRule{},
+		worldWritableDirRule{},
+		broadDefaultRule{},
+		broadMaskRule{},
+	}
+	if resolver != nil {
+		rules = append(rules, unknownPrincipalRule{resolver: resolver})
+	}
+	return rules
+}
+
+// Lint runs all rules against the target
+func (l *Linter) Lint(t *LintTarget) ([]*LintFinding, error) {
+	result := []*LintFinding{}
+	for _, r := range l.Rules {
+		findings, err := r.Check(t)
+		if err != nil {
+			return nil, fmt.Errorf("lint rule %s: %w", r.Name(), err)
+		}
+		result = append(result, findings...)
+	}
+	return result, nil
+}
+
+// LintPath loads the metadata and ACLs of fsPath and lints them
+func (l *Linter) LintPath(fsPath string) ([]*LintFinding, error) {
+	t, err := loadLintTarget(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	return l.Lint(t)
+}
+
+// loadLintTarget loads metadata and ACLs of fsPath
+func loadLintTarget(fsPath string) (*LintTarget, error) {
+	info, err := os.Stat(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	t := &LintTarget{Path: fsPath, Info: info, Access: NewACL()}
+	exists, err := t.Access.load(fsPath, PosixACLAccess)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// a file without ACL has no mask
+		t.Access.DeleteEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0))
+	}
+	if info.IsDir() {
+		t.Default, err = loadForCompare(fsPath, PosixACLDefault, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(t.Default.entries) == 0 {
+			t.Default = nil
+		}
+	}
+	return t, nil
+}
+
+// LintReport is the outcome of LintTree
+type LintReport struct {
+	Root string `json:"root"`
+	// Scanned is the number of paths visited
+	Scanned int64 `json:"scanned"`
+	// Findings are ordered by path and rule
+	Findings []*LintFinding `json:"findings"`
+	Errors   []*BulkError   `json:"errors,omitempty"`
+}
+
+// LintTree lints the tree below root. Workers, RateLimit, Progress and
+// Logger of opts are honored, Progress reports paths with findings
+// as Changed.
+func (l *Linter) LintTree(ctx context.Context, root string, opts BulkOptions) (*LintReport, error) {
+	b := newBulkRun(opts)
+	mu := sync.Mutex{}
+	report := &LintReport{Root: root, Findings: []*LintFinding{}}
+
+	err := walkTree(ctx, root, opts.Workers, opts.RateLimit, func(fsPath string, d fs.DirEntry, err error) {
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		findings, err := l.LintPath(fsPath)
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		mu.Lock()
+		report.Findings = append(report.Findings, findings...)
+		mu.Unlock()
+		b.record(fsPath, len(findings) > 0, nil)
+	})
+
+	result := b.finish()
+	report.Scanned = result.Scanned
+	report.Errors = result.Errors
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Path != report.Findings[j].Path {
+			return report.Findings[i].Path < report.Findings[j].Path
+		}
+		return report.Findings[i].Rule < report.Findings[j].Rule
+	})
+	return report, err
+}
+
+// JSON renders the report as indented JSON
+func (r *LintReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// lintACL is an ACL of a LintTarget with its attr
+type lintACL struct {
+	attr ACLAttr
+	acl  *ACL
+}
+
+// acls returns the ACLs of the target, access first
+func (t *LintTarget) acls() []lintACL {
+	result := []lintACL{{attr: PosixACLAccess, acl: t.Access}}
+	if t.Default != nil {
+		result = append(result, lintACL{attr: PosixACLDefault, acl: t.Default})
+	}
+	return result
+}
+
+// otherWritableRule flags OTHER entries granting write
+type otherWritableRule struct{}
+
+func (otherWritableRule) Name() string { return "other-writable" }
+
+func (r otherWritableRule) Check(t *LintTarget) ([]*LintFinding, error) {
+	result := []*LintFinding{}
+	for _, la := range t.acls() {
+		attr, a := la.attr, la.acl
+		if e := a.GetEntry(NewEntry(TAG_ACL_OTHER, ACL_UNDEFINED_ID, 0)); e != nil && e.HasPerm(PermWrite) {
+			result = append(result, &LintFinding{Rule: r.Name(), Severity: SeverityCritical, Path: t.Path, Attr: attr, Entry: e,
+				Message: "everyone is granted write permission"})
+		}
+	}
+	return result, nil
+}
+
+// deadEntryRule flags named entries granting permissions
+// that are completely removed by the mask
+type deadEntryRule struct{}
+
+func (deadEntryRule) Name() string { return "dead-entry" }
+
+func (r deadEntryRule) Check(t *LintTarget) ([]*LintFinding, error) {
+	result := []*LintFinding{}
+	for _, la := range t.acls() {
+		attr, a := la.attr, la.acl
+		mask := a.GetEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0))
+		if mask == nil {
+			continue
+		}
+		for _, e := range a.entries {
+			if (e.tag == TAG_ACL_USER || e.tag == TAG_ACL_GROUP) && e.perm != 0 && e.perm&mask.perm == 0 {
+				result = append(result, &LintFinding{Rule: r.Name(), Severity: SeverityWarning, Path: t.Path, Attr: attr, Entry: e,
+					Message: fmt.Sprintf("entry grants nothing, all permissions are removed by mask %s", PermUintToString(mask.perm))})
+			}
+		}
+	}
+	return result, nil
+}
+
+// worldWritableDirRule flags world writable directories without sticky bit
+type worldWritableDirRule struct{}
+
+func (worldWritableDirRule) Name() string { return "world-writable-dir" }
+
+func (r worldWritableDirRule) Check(t *LintTarget) ([]*LintFinding, error) {
+	if t.Info == nil || !t.Info.IsDir() {
+		return nil, nil
+	}
+	if t.Info.Mode().Perm()&0o002 != 0 && t.Info.Mode()&fs.ModeSticky == 0 {
+		return []*LintFinding{{Rule: r.Name(), Severity: SeverityCritical, Path: t.Path,
+			Message: "directory is world writable without sticky bit, anyone can delete or rename entries"}}, nil
+	}
+	return nil, nil
+}
+
+// broadDefaultRule flags default ACL entries granting more than
+// the corresponding access ACL entry
+type broadDefaultRule struct{}
+
+func (broadDefaultRule) Name() string { return "broad-default" }
+
+func (r broadDefaultRule) Check(t *LintTarget) ([]*LintFinding, error) {
+	if t.Default == nil {
+		return nil, nil
+	}
+	result := []*LintFinding{}
+	for _, d := range t.Access.Diff(t.Default) {
+		var extra uint16
+		switch d.Kind {
+		case DiffAdded:
+			extra = d.NewPerm
+		case DiffModified:
+			extra = d.NewPerm &^ d.OldPerm
+		}
+		if extra == 0 || d.Tag == TAG_ACL_MASK {
+			continue
+		}
+		result = append(result, &LintFinding{Rule: r.Name(), Severity: SeverityWarning, Path: t.Path, Attr: PosixACLDefault,
+			Entry:   t.Default.GetEntry(NewEntry(d.Tag, d.ID, 0)),
+			Message: fmt.Sprintf("default ACL grants %s beyond the access ACL of the directory", PermUintToString(extra))})
+	}
+	return result, nil
+}
+
+// broadMaskRule flags masks granting permissions
+// no entry of the group class has
+type broadMaskRule struct{}
+
+func (broadMaskRule) Name() string { return "broad-mask" }
+
+func (r broadMaskRule) Check(t *LintTarget) ([]*LintFinding, error) {
+	result := []*LintFinding{}
+	for _, la := range t.acls() {
+		attr, a := la.attr, la.acl
+		mask := a.GetEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0))
+		if mask == nil {
+			continue
+		}
+		var union uint16
+		for _, e := range a.entries {
+			if e.tag == TAG_ACL_USER || e.tag == TAG_ACL_GROUP_OBJ || e.tag == TAG_ACL_GROUP {
+				union |= e.perm
+			}
+		}
+		if extra := mask.perm &^ union; extra != 0 {
+			result = append(result, &LintFinding{Rule: r.Name(), Severity: SeverityInfo, Path: t.Path, Attr: attr, Entry: mask,
+				Message: fmt.Sprintf("mask allows %s which no group class entry grants", PermUintToString(extra))})
+		}
+	}
+	return result, nil
+}
+
+// unknownPrincipalRule flags named entries referencing
+// users or groups unknown to the resolver
+type unknownPrincipalRule struct {
+	resolver IDResolver
+}
+
+func (unknownPrincipalRule) Name() string { return "unknown-principal" }
+
+func (r unknownPrincipalRule) Check(t *LintTarget) ([]*LintFinding, error) {
+	result := []*LintFinding{}
+	for _, la := range t.acls() {
+		attr, a := la.attr, la.acl
+		for _, e := range a.entries {
+			ok, err := entryPrincipalExists(r.resolver, e)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				result = append(result, &LintFinding{Rule: r.Name(), Severity: SeverityWarning, Path: t.Path, Attr: attr, Entry: e,
+					Message: fmt.Sprintf("%s %d does not exist", Tag2String(e.tag), e.id)})
+			}
+		}
+	}
+	return result, nil
+}
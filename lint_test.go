@@ -0,0 +1,143 @@
+package acls
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mapResolver is an IDResolver knowing only the given IDs
+type mapResolver struct {
+	users  map[uint32]bool
+	groups map[uint32]bool
+}
+
+func (r mapResolver) UserExists(uid uint32) (bool, error)  { return r.users[uid], nil }
+func (r mapResolver) GroupExists(gid uint32) (bool, error) { return r.groups[gid], nil }
+
+func lintRuleNames(findings []*LintFinding) map[string]int {
+	result := map[string]int{}
+	for _, f := range findings {
+		result[f.Rule]++
+	}
+	return result
+}
+
+func TestLinter_Lint(t *testing.T) {
+	resolver := mapResolver{users: map[uint32]bool{1000: true}, groups: map[uint32]bool{2000: true}}
+	linter := NewLinter(DefaultLintRules(resolver)...)
+
+	tests := []struct {
+		name   string
+		target *LintTarget
+		want   map[string]int
+	}{
+		{
+			name: "clean",
+			target: &LintTarget{Path: "/clean", Access: &ACL{version: 2, entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, math.MaxUint32, PermAll),
+				NewEntry(TAG_ACL_USER, 1000, PermRead),
+				NewEntry(TAG_ACL_GROUP_OBJ, math.MaxUint32, PermRead),
+				NewEntry(TAG_ACL_MASK, math.MaxUint32, PermRead),
+				NewEntry(TAG_ACL_OTHER, math.MaxUint32, PermNone),
+			}}},
+			want: map[string]int{},
+		},
+		{
+			name: "risky access ACL",
+			target: &LintTarget{Path: "/risky", Access: &ACL{version: 2, entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, math.MaxUint32, PermAll),
+				NewEntry(TAG_ACL_USER, 1000, PermWrite),
+				NewEntry(TAG_ACL_USER, 1001, PermRead),
+				NewEntry(TAG_ACL_GROUP_OBJ, math.MaxUint32, PermRead),
+				NewEntry(TAG_ACL_GROUP, 2001, PermRead),
+				NewEntry(TAG_ACL_MASK, math.MaxUint32, PermRead|PermExecute),
+				NewEntry(TAG_ACL_OTHER, math.MaxUint32, PermRead|PermWrite),
+			}}},
+			want: map[string]int{
+				"other-writable":    1,
+				"dead-entry":        1,
+				"broad-mask":        1,
+				"unknown-principal": 2,
+			},
+		},
+		{
+			name: "broad default ACL",
+			target: &LintTarget{
+				Path: "/dir",
+				Access: &ACL{version: 2, entries: []*ACLEntry{
+					NewEntry(TAG_ACL_USER_OBJ, math.MaxUint32, PermAll),
+					NewEntry(TAG_ACL_GROUP_OBJ, math.MaxUint32, PermRead|PermExecute),
+					NewEntry(TAG_ACL_OTHER, math.MaxUint32, PermNone),
+				}},
+				Default: &ACL{version: 2, entries: []*ACLEntry{
+					NewEntry(TAG_ACL_USER_OBJ, math.MaxUint32, PermAll),
+					NewEntry(TAG_ACL_GROUP_OBJ, math.MaxUint32, PermAll),
+					NewEntry(TAG_ACL_GROUP, 2000, PermRead),
+					NewEntry(TAG_ACL_MASK, math.MaxUint32, PermAll),
+					NewEntry(TAG_ACL_OTHER, math.MaxUint32, PermNone),
+				}},
+			},
+			want: map[string]int{"broad-default": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := linter.Lint(tt.target)
+			if err != nil {
+				t.Fatalf("Lint() unexpected error %v", err)
+			}
+			got := lintRuleNames(findings)
+			if len(got) != len(tt.want) {
+				t.Errorf("Lint() = %v, want %v", got, tt.want)
+			}
+			for rule, n := range tt.want {
+				if got[rule] != n {
+					t.Errorf("rule %s: got %d findings, want %d", rule, got[rule], n)
+				}
+			}
+		})
+	}
+}
+
+func TestLinter_LintTree(t *testing.T) {
+	root := createTestTree(t, "shared/", "file1")
+	shared := filepath.Join(root, "shared")
+	if err := os.Chmod(shared, 0o777); err != nil {
+		t.Fatalf("failed chmod %v", err)
+	}
+
+	linter := NewLinter(DefaultLintRules(nil)...)
+	report, err := linter.LintTree(context.Background(), root, BulkOptions{})
+	if err != nil {
+		t.Fatalf("LintTree() unexpected error %v", err)
+	}
+	if report.Scanned != 3 {
+		t.Errorf("expected 3 scanned paths, got %d", report.Scanned)
+	}
+	got := lintRuleNames(report.Findings)
+	if got["world-writable-dir"] != 1 || got["other-writable"] != 1 || len(got) != 2 {
+		t.Errorf("unexpected findings %v", got)
+	}
+	for _, f := range report.Findings {
+		if f.Path != shared {
+			t.Errorf("unexpected finding for %q: %s", f.Path, f.Message)
+		}
+	}
+
+	if err := os.Chmod(shared, 0o777|os.ModeSticky); err != nil {
+		t.Fatalf("failed chmod %v", err)
+	}
+	findings, err := linter.LintPath(shared)
+	if err != nil {
+		t.Fatalf("LintPath() unexpected error %v", err)
+	}
+	if got := lintRuleNames(findings); got["world-writable-dir"] != 0 {
+		t.Errorf("sticky directory flagged: %v", got)
+	}
+	if _, err := report.JSON(); err != nil {
+		t.Errorf("JSON() unexpected error %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package acls
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// maxMinimizeGroups limits the number of distinct groups of an ACL
+// Minimize verifies, as every combination of memberships is checked
+const maxMinimizeGroups = 8
+
+// maxMinimizeSearch limits the number of removable entries for which
+// Minimize searches the largest jointly removable set exhaustively,
+// above it entries are removed greedily
+const maxMinimizeSearch = 6
+
+// maxMinimizeEvaluations limits the number of credentials Minimize
+// evaluates in total, each for all combinations of permissions
+const maxMinimizeEvaluations = 1 << 18
+
+// Minimize returns an ACL granting every credential exactly the same
+// access with the fewest entries. Named entries are removed if they
+// grant nothing beyond what the remaining entries grant, e.g. because
+// OTHER or the owning group already give the same permissions or the
+// entry is clipped by the mask without changing the outcome.
+// If collapse is set and no named entries remain, the mask is folded
+// into the owning group entry, resulting in a minimal ACL.
+//
+// Every removal is verified with Allowed against all distinct
+// credentials the ACL can tell apart. ACLs referencing more than 8
+// distinct groups, or requiring more evaluations than a fixed budget,
+// are returned unchanged, as verifying them is too expensive. Entries
+// that are only redundant in combination with others are kept.
+func (a *ACL) Minimize(collapse bool) *ACL {
+	result := a.Clone()
+	u := newCredentialUniverse(a)
+	if len(u.gids) > maxMinimizeGroups {
+		return result
+	}
+	u.budget = maxMinimizeEvaluations
+
+	// entries that can be removed on their own
+	candidates := []*ACLEntry{}
+	for _, e := range result.entries {
+		if e.tag != TAG_ACL_USER && e.tag != TAG_ACL_GROUP {
+			continue
+		}
+		trial := result.Clone()
+		trial.DeleteEntry(e)
+		if u.equivalent(a, trial) {
+			candidates = append(candidates, e)
+		}
+	}
+
+	if len(candidates) <= maxMinimizeSearch {
+		result = u.largestRemoval(a, result, candidates)
+	} else {
+		for _, e := range candidates {
+			trial := result.Clone()
+			trial.DeleteEntry(e)
+			if u.equivalent(a, trial) {
+				result = trial
+			}
+		}
+	}
+
+	if collapse && !result.hasNamedEntries() {
+		trial := result.Clone()
+		if mask := trial.DeleteEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0)); mask != nil {
+			if g := trial.GetEntry(NewEntry(TAG_ACL_GROUP_OBJ, ACL_UNDEFINED_ID, 0)); g != nil {
+				trial.AddEntry(g.WithExactPerm(g.perm & mask.perm))
+			}
+			if u.equivalent(a, trial) {
+				result = trial
+			}
+		}
+	}
+	if u.budget < 0 {
+		return a.Clone()
+	}
+	return result
+}
+
+// largestRemoval returns base without the largest subset of candidates
+// that can be removed without changing the access of any credential
+func (u *credentialUniverse) largestRemoval(orig, base *ACL, candidates []*ACLEntry) *ACL {
+	n := len(candidates)
+	for size := n; size > 0; size-- {
+		for set := uint(0); set < 1<<n; set++ {
+			if bits.OnesCount(set) != size {
+				continue
+			}
+			trial := base.Clone()
+			for i, e := range candidates {
+				if set&(1<<i) != 0 {
+					trial.DeleteEntry(e)
+				}
+			}
+			if u.equivalent(orig, trial) {
+				return trial
+			}
+		}
+	}
+	return base
+}
+
+// credentialUniverse holds the IDs needed to construct all
+// credentials an ACL can distinguish
+type credentialUniverse struct {
+	owner uint32
+	group uint32
+	// uids are the named users plus one unrelated user
+	uids []uint32
+	// gids are the named groups plus the owning group
+	gids []uint32
+	// creds are all credentials of the universe, built on first use
+	creds []Credential
+	// base and baseAccess cache the access the ACL compared
+	// last as first argument of equivalent grants
+	base       *ACL
+	baseAccess []uint8
+	// budget is the number of credential evaluations left, it is
+	// negative once exhausted. Zero means unlimited.
+	budget int
+}
+
+// newCredentialUniverse determines the credentials relevant for a.
+// Owner and owning group are represented by IDs not used otherwise,
+// membership in the owning group is varied like any other group.
+func newCredentialUniverse(a *ACL) *credentialUniverse {
+	used := map[uint32]bool{ACL_UNDEFINED_ID: true}
+	u := &credentialUniverse{}
+	for _, e := range a.entries {
+		switch e.tag {
+		case TAG_ACL_USER:
+			u.uids = append(u.uids, e.id)
+			used[e.id] = true
+		case TAG_ACL_GROUP:
+			u.gids = append(u.gids, e.id)
+			used[e.id] = true
+		}
+	}
+	fresh := func() uint32 {
+		id := uint32(0)
+		for used[id] {
+			id++
+		}
+		used[id] = true
+		return id
+	}
+	u.owner = fresh()
+	u.group = fresh()
+	u.uids = append(u.uids, fresh())
+	u.gids = append(u.gids, u.group)
+	return u
+}
+
+// credentials returns all credentials of the universe
+func (u *credentialUniverse) credentials() []Credential {
+	if u.creds != nil {
+		return u.creds
+	}
+	u.creds = []Credential{{UID: u.owner}}
+	for set := 0; set < 1<<len(u.gids); set++ {
+		gids := []uint32{}
+		for i, gid := range u.gids {
+			if set&(1<<i) != 0 {
+				gids = append(gids, gid)
+			}
+		}
+		for _, uid := range u.uids {
+			u.creds = append(u.creds, Credential{UID: uid, GIDs: gids})
+		}
+	}
+	return u.creds
+}
+
+// access returns, for every credential, the set of permission
+// combinations the ACL grants as bitmap indexed by the combination
+func (u *credentialUniverse) access(a *ACL) []uint8 {
+	ev := newACLEvaluator(a)
+	creds := u.credentials()
+	result := make([]uint8, len(creds))
+	for i, c := range creds {
+		for want := uint16(1); want <= PermAll; want++ {
+			if ev.allowed(u.owner, u.group, c, want) {
+				result[i] |= 1 << want
+			}
+		}
+	}
+	return result
+}
+
+// spend charges the evaluation of all credentials against the budget
+// and reports whether the budget still allows it
+func (u *credentialUniverse) spend() bool {
+	if u.budget == 0 {
+		return true
+	}
+	u.budget -= len(u.credentials())
+	if u.budget <= 0 {
+		u.budget = -1
+		return false
+	}
+	return true
+}
+
+// equivalent reports whether a and b grant the same access to
+// every credential of the universe. It reports false once the
+// evaluation budget is exhausted.
+func (u *credentialUniverse) equivalent(a, b *ACL) bool {
+	if u.base != a {
+		if !u.spend() {
+			return false
+		}
+		u.base, u.baseAccess = a, u.access(a)
+	}
+	if !u.spend() {
+		return false
+	}
+	return slices.Equal(u.baseAccess, u.access(b))
+}
@@ -0,0 +1,150 @@
+package acls
+
+import (
+	"math"
+	"testing"
+)
+
+func TestACL_Minimize(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	tests := []struct {
+		name     string
+		entries  []*ACLEntry
+		collapse bool
+		want     []*ACLEntry
+	}{
+		{
+			name: "group entry equal to owning group",
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 3000, 5),
+				NewEntry(TAG_ACL_MASK, u, 7),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_MASK, u, 7),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+		},
+		{
+			name: "collapsed to minimal ACL",
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP, 3000, 5),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+			collapse: true,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+		},
+		{
+			name: "user entry masked to other",
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 2000, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_MASK, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_MASK, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+		},
+		{
+			name: "restricting entries kept",
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 2000, 0),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 3000, 0),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+			collapse: true,
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 2000, 0),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 3000, 0),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+		},
+		{
+			name: "mask kept without collapse",
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 7),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 7),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &ACL{version: 2, entries: tt.entries}
+			before := a.String()
+			got := a.Minimize(tt.collapse)
+			want := &ACL{version: 2, entries: tt.want}
+			if !got.Equal(want) {
+				t.Errorf("Minimize() = %v, want %v", got, want)
+			}
+			if a.String() != before {
+				t.Errorf("Minimize() modified the receiver")
+			}
+			if !newCredentialUniverse(a).equivalent(a, got) {
+				t.Errorf("Minimize() changed effective permissions")
+			}
+		})
+	}
+}
+
+func TestACL_MinimizeBounded(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	tests := []struct {
+		name   string
+		users  int
+		groups int
+	}{
+		// more groups than verifiable
+		{name: "many groups", users: 8, groups: 11},
+		// few enough groups, but exceeding the evaluation budget
+		{name: "many users", users: 40, groups: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewACL()
+			a.AddEntry(NewEntry(TAG_ACL_USER_OBJ, u, 7))
+			a.AddEntry(NewEntry(TAG_ACL_GROUP_OBJ, u, 5))
+			a.AddEntry(NewEntry(TAG_ACL_MASK, u, 7))
+			a.AddEntry(NewEntry(TAG_ACL_OTHER, u, 5))
+			// all named entries are redundant to OTHER
+			for i := range tt.users {
+				a.AddEntry(NewEntry(TAG_ACL_USER, uint32(1000+i), 5))
+			}
+			for i := range tt.groups {
+				a.AddEntry(NewEntry(TAG_ACL_GROUP, uint32(2000+i), 5))
+			}
+			if got := a.Minimize(true); !got.Equal(a) {
+				t.Errorf("Minimize() = %v, want the input unchanged", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package acls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// NFS4ACEType is the type of an NFSv4 ACE
+type NFS4ACEType uint32
+
+// NFSv4 ACE types, see RFC 7530 section 6.2.1.1
+const (
+	NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE NFS4ACEType = 0x0
+	NFS4_ACE_ACCESS_DENIED_ACE_TYPE  NFS4ACEType = 0x1
+	NFS4_ACE_SYSTEM_AUDIT_ACE_TYPE   NFS4ACEType = 0x2
+	NFS4_ACE_SYSTEM_ALARM_ACE_TYPE   NFS4ACEType = 0x3
+)
+
+// String returns the name of the ACE type
+func (t NFS4ACEType) String() string {
+	switch t {
+	case NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE:
+		return "ALLOW"
+	case NFS4_ACE_ACCESS_DENIED_ACE_TYPE:
+		return "DENY"
+	case NFS4_ACE_SYSTEM_AUDIT_ACE_TYPE:
+		return "AUDIT"
+	case NFS4_ACE_SYSTEM_ALARM_ACE_TYPE:
+		return "ALARM"
+	}
+	return fmt.Sprintf("0x%x", uint32(t))
+}
+
+// NFS4ACEFlag holds the flags of an NFSv4 ACE
+type NFS4ACEFlag uint32
+
+// NFSv4 ACE flags, see RFC 7530 section 6.2.1.4
+const (
+	NFS4_ACE_FILE_INHERIT_ACE           NFS4ACEFlag = 0x1
+	NFS4_ACE_DIRECTORY_INHERIT_ACE      NFS4ACEFlag = 0x2
+	NFS4_ACE_NO_PROPAGATE_INHERIT_ACE   NFS4ACEFlag = 0x4
+	NFS4_ACE_INHERIT_ONLY_ACE           NFS4ACEFlag = 0x8
+	NFS4_ACE_SUCCESSFUL_ACCESS_ACE_FLAG NFS4ACEFlag = 0x10
+	NFS4_ACE_FAILED_ACCESS_ACE_FLAG     NFS4ACEFlag = 0x20
+	// NFS4_ACE_IDENTIFIER_GROUP marks the who of the ACE as group
+	NFS4_ACE_IDENTIFIER_GROUP NFS4ACEFlag = 0x40
+	NFS4_ACE_INHERITED_ACE    NFS4ACEFlag = 0x80
+)
+
+// NFS4AccessMask holds the permission bits of an NFSv4 ACE
+type NFS4AccessMask uint32
+
+// NFSv4 access mask bits, see RFC 7530 section 6.2.1.3.
+// Some bits have a different meaning for directories.
+const (
+	NFS4_ACE_READ_DATA            NFS4AccessMask = 0x00000001
+	NFS4_ACE_LIST_DIRECTORY       NFS4AccessMask = 0x00000001
+	NFS4_ACE_WRITE_DATA           NFS4AccessMask = 0x00000002
+	NFS4_ACE_ADD_FILE             NFS4AccessMask = 0x00000002
+	NFS4_ACE_APPEND_DATA          NFS4AccessMask = 0x00000004
+	NFS4_ACE_ADD_SUBDIRECTORY     NFS4AccessMask = 0x00000004
+	NFS4_ACE_READ_NAMED_ATTRS     NFS4AccessMask = 0x00000008
+	NFS4_ACE_WRITE_NAMED_ATTRS    NFS4AccessMask = 0x00000010
+	NFS4_ACE_EXECUTE              NFS4AccessMask = 0x00000020
+	NFS4_ACE_DELETE_CHILD         NFS4AccessMask = 0x00000040
+	NFS4_ACE_READ_ATTRIBUTES      NFS4AccessMask = 0x00000080
+	NFS4_ACE_WRITE_ATTRIBUTES     NFS4AccessMask = 0x00000100
+	NFS4_ACE_WRITE_RETENTION      NFS4AccessMask = 0x00000200
+	NFS4_ACE_WRITE_RETENTION_HOLD NFS4AccessMask = 0x00000400
+	NFS4_ACE_DELETE               NFS4AccessMask = 0x00010000
+	NFS4_ACE_READ_ACL             NFS4AccessMask = 0x00020000
+	NFS4_ACE_WRITE_ACL            NFS4AccessMask = 0x00040000
+	NFS4_ACE_WRITE_OWNER          NFS4AccessMask = 0x00080000
+	NFS4_ACE_SYNCHRONIZE          NFS4AccessMask = 0x00100000
+)
+
+// Special who principals of NFSv4 ACEs
+const (
+	NFS4WhoOwner    = "OWNER@"
+	NFS4WhoGroup    = "GROUP@"
+	NFS4WhoEveryone = "EVERYONE@"
+)
+
+// NFS4ACE is an access control entry of an NFSv4 ACL
+type NFS4ACE struct {
+	Type  NFS4ACEType
+	Flags NFS4ACEFlag
+	Mask  NFS4AccessMask
+	// Who is a special principal like OWNER@, a user or group name
+	// in the form name@domain or a numeric ID
+	Who string
+}
+
+// Tag returns the POSIX tag corresponding to the who of the ACE:
+// TAG_ACL_USER_OBJ for OWNER@, TAG_ACL_GROUP_OBJ for GROUP@,
+// TAG_ACL_EVERYONE for EVERYONE@ and TAG_ACL_USER or TAG_ACL_GROUP,
+// depending on NFS4_ACE_IDENTIFIER_GROUP, for all others.
+func (e *NFS4ACE) Tag() Tag {
+	switch e.Who {
+	case NFS4WhoOwner:
+		return TAG_ACL_USER_OBJ
+	case NFS4WhoGroup:
+		return TAG_ACL_GROUP_OBJ
+	case NFS4WhoEveryone:
+		return TAG_ACL_EVERYONE
+	}
+	if e.Flags&NFS4_ACE_IDENTIFIER_GROUP != 0 {
+		return TAG_ACL_GROUP
+	}
+	return TAG_ACL_USER
+}
+
+// String returns a string representation of the NFS4ACE
+func (e *NFS4ACE) String() string {
+	return fmt.Sprintf("Type: %5s, Flags: 0x%02x, Mask: 0x%06x, Who: %s", e.Type, uint32(e.Flags), uint32(e.Mask), e.Who)
+}
+
+// parse parses a single XDR encoded ACE from b and
+// returns the remaining bytes
+func (e *NFS4ACE) parse(b []byte) ([]byte, error) {
+	if len(b) < 16 {
+		return nil, fmt.Errorf("malformed nfs4 ace: expected at least 16 bytes, got %d", len(b))
+	}
+	e.Type = NFS4ACEType(binary.BigEndian.Uint32(b[0:4]))
+	e.Flags = NFS4ACEFlag(binary.BigEndian.Uint32(b[4:8]))
+	e.Mask = NFS4AccessMask(binary.BigEndian.Uint32(b[8:12]))
+	whoLen := uint64(binary.BigEndian.Uint32(b[12:16]))
+	padded := (whoLen + 3) &^ 3
+	b = b[16:]
+	if uint64(len(b)) < padded {
+		return nil, fmt.Errorf("malformed nfs4 ace: who of %d bytes exceeds data", whoLen)
+	}
+	e.Who = string(b[:whoLen])
+	return b[padded:], nil
+}
+
+// ToByteSlice writes the XDR encoding of the ACE to result
+func (e *NFS4ACE) ToByteSlice(result *bytes.Buffer) {
+	binary.Write(result, binary.BigEndian, uint32(e.Type))
+	binary.Write(result, binary.BigEndian, uint32(e.Flags))
+	binary.Write(result, binary.BigEndian, uint32(e.Mask))
+	binary.Write(result, binary.BigEndian, uint32(len(e.Who)))
+	result.WriteString(e.Who)
+	result.Write(make([]byte, (4-len(e.Who)%4)%4))
+}
+
+// NFS4ACL is an NFSv4 ACL as stored in the system.nfs4_acl xattr.
+// Unlike POSIX ACLs the ACEs are evaluated in order.
+type NFS4ACL struct {
+	ACEs []*NFS4ACE
+}
+
+// Load loads the NFSv4 ACL of the given filepath
+func (a *NFS4ACL) Load(fsPath string) error {
+	return readXattr(fsPath, NFS4ACLAttr, a.parse)
+}
+
+// Apply applies the NFSv4 ACL to the given filepath
+func (a *NFS4ACL) Apply(fsPath string) error {
+	b := &bytes.Buffer{}
+	a.ToByteSlice(b)
+	return unix.Setxattr(fsPath, string(NFS4ACLAttr), b.Bytes(), 0)
+}
+
+// ToByteSlice writes the XDR encoding of the ACL,
+// as used by the system.nfs4_acl xattr, to result
+func (a *NFS4ACL) ToByteSlice(result *bytes.Buffer) {
+	binary.Write(result, binary.BigEndian, uint32(len(a.ACEs)))
+	for _, e := range a.ACEs {
+		e.ToByteSlice(result)
+	}
+}
+
+// parse parses the XDR encoded ACL and replaces the ACEs of a
+func (a *NFS4ACL) parse(b []byte) error {
+	if len(b) < 4 {
+		return fmt.Errorf("malformed nfs4 acl: expected at least a 32 bit header, got %d bytes", len(b))
+	}
+	count := binary.BigEndian.Uint32(b[:4])
+	remainder := b[4:]
+	// every ACE takes at least 16 bytes, reject counts the data cannot hold
+	if uint64(count)*16 > uint64(len(remainder)) {
+		return fmt.Errorf("malformed nfs4 acl: %d aces exceed %d bytes of data", count, len(remainder))
+	}
+	aces := make([]*NFS4ACE, 0, count)
+	var err error
+	for i := uint32(0); i < count; i++ {
+		e := &NFS4ACE{}
+		if remainder, err = e.parse(remainder); err != nil {
+			return err
+		}
+		aces = append(aces, e)
+	}
+	if len(remainder) != 0 {
+		return fmt.Errorf("malformed nfs4 acl: %d trailing bytes", len(remainder))
+	}
+	a.ACEs = aces
+	return nil
+}
+
+// Equal returns true if both ACLs hold the same ACEs in the same order
+func (a *NFS4ACL) Equal(e *NFS4ACL) bool {
+	if len(a.ACEs) != len(e.ACEs) {
+		return false
+	}
+	for i := range a.ACEs {
+		if *a.ACEs[i] != *e.ACEs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a human readable form of the NFS4ACL
+func (a *NFS4ACL) String() string {
+	sb := &strings.Builder{}
+	for _, e := range a.ACEs {
+		sb.WriteString(e.String())
+		sb.WriteString("\n")
+	}
+	return fmt.Sprintf("ACEs:\n%s", sb.String())
+}
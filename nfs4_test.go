@@ -0,0 +1,73 @@
+package acls
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// nfs4Fixture is an XDR encoded ACL as returned for system.nfs4_acl
+const nfs4Fixture = "00000003" +
+	// ALLOW OWNER@ rwaxtTnNcCy
+	"00000000" + "00000000" + "001601bf" + "00000006" + "4f574e4552400000" +
+	// DENY group 1000 w, inherited to files and directories
+	"00000001" + "00000043" + "00000002" + "00000004" + "31303030" +
+	// ALLOW EVERYONE@ rtcy
+	"00000000" + "00000000" + "00120081" + "00000009" + "45564552594f4e4540000000"
+
+func TestNFS4ACL_parse(t *testing.T) {
+	b, _ := hex.DecodeString(nfs4Fixture)
+	want := &NFS4ACL{ACEs: []*NFS4ACE{
+		{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: 0x1601bf, Who: NFS4WhoOwner},
+		{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE, Flags: NFS4_ACE_FILE_INHERIT_ACE | NFS4_ACE_DIRECTORY_INHERIT_ACE | NFS4_ACE_IDENTIFIER_GROUP, Mask: NFS4_ACE_WRITE_DATA, Who: "1000"},
+		{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: 0x120081, Who: NFS4WhoEveryone},
+	}}
+	a := &NFS4ACL{}
+	if err := a.parse(b); err != nil {
+		t.Fatalf("parse() unexpected error %v", err)
+	}
+	if !a.Equal(want) {
+		t.Errorf("parse() = %v, want %v", a, want)
+	}
+	wantTags := []Tag{TAG_ACL_USER_OBJ, TAG_ACL_GROUP, TAG_ACL_EVERYONE}
+	for i, e := range a.ACEs {
+		if e.Tag() != wantTags[i] {
+			t.Errorf("ACE %d Tag() = %s, want %s", i, Tag2String(e.Tag()), Tag2String(wantTags[i]))
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	a.ToByteSlice(buf)
+	if got := hex.EncodeToString(buf.Bytes()); got != nfs4Fixture {
+		t.Errorf("ToByteSlice() = %s, want %s", got, nfs4Fixture)
+	}
+}
+
+func TestNFS4ACL_parseMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "count exceeds data", input: "ffffffff"},
+		{name: "truncated ace", input: "00000001" + "00000000" + "00000000"},
+		{name: "who exceeds data", input: "00000001" + "00000000" + "00000000" + "00000001" + "00000008" + "41424344"},
+		{name: "trailing bytes", input: "00000000" + "00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.input)
+			if err := (&NFS4ACL{}).parse(b); err == nil {
+				t.Errorf("parse() expected error")
+			}
+		})
+	}
+}
+
+func TestNFS4ACL_String(t *testing.T) {
+	a := &NFS4ACL{ACEs: []*NFS4ACE{{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: NFS4_ACE_WRITE_DATA, Who: "staff@example.com"}}}
+	want := "ACEs:\nType:  DENY, Flags: 0x40, Mask: 0x000002, Who: staff@example.com\n"
+	if got := a.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
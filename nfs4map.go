@@ -0,0 +1,445 @@
+package acls
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Groups of NFSv4 access mask bits used by the POSIX mapping, see
+// draft-ietf-nfsv4-acl-mapping and the Linux NFS server
+const (
+	// nfs4AnyoneMode is granted to everyone by POSIX
+	nfs4AnyoneMode = NFS4_ACE_READ_ATTRIBUTES | NFS4_ACE_READ_ACL | NFS4_ACE_SYNCHRONIZE
+	// nfs4OwnerMode is granted to the owner by POSIX
+	nfs4OwnerMode   = NFS4_ACE_WRITE_ATTRIBUTES | NFS4_ACE_WRITE_ACL
+	nfs4ReadMode    = NFS4_ACE_READ_DATA
+	nfs4WriteMode   = NFS4_ACE_WRITE_DATA | NFS4_ACE_APPEND_DATA
+	nfs4ExecuteMode = NFS4_ACE_EXECUTE
+	// nfs4NamedAttrs are ignored by the mapping
+	nfs4NamedAttrs = NFS4_ACE_READ_NAMED_ATTRS | NFS4_ACE_WRITE_NAMED_ATTRS
+	// nfs4InheritFlags mark ACEs of the default ACL
+	nfs4InheritFlags = NFS4_ACE_FILE_INHERIT_ACE | NFS4_ACE_DIRECTORY_INHERIT_ACE
+)
+
+// NFS4WhoMapper translates between the qualifiers of named POSIX
+// entries and NFSv4 who principals
+type NFS4WhoMapper interface {
+	// Who returns the principal of a TAG_ACL_USER or TAG_ACL_GROUP qualifier
+	Who(tag Tag, id uint32) (string, error)
+	// ID returns the qualifier of a TAG_ACL_USER or TAG_ACL_GROUP principal
+	ID(tag Tag, who string) (uint32, error)
+}
+
+// NumericWhoMapper uses decimal IDs as principals, like NFSv4
+// clients and servers do with ID mapping disabled
+type NumericWhoMapper struct{}
+
+// Who returns id as decimal string
+func (NumericWhoMapper) Who(_ Tag, id uint32) (string, error) {
+	return strconv.FormatUint(uint64(id), 10), nil
+}
+
+// ID parses who as decimal ID
+func (NumericWhoMapper) ID(_ Tag, who string) (uint32, error) {
+	id, err := strconv.ParseUint(who, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("principal %q is not a numeric id", who)
+	}
+	return uint32(id), nil
+}
+
+// POSIXToNFS4 converts the access ACL and, for directories, the default
+// ACL to an NFSv4 ACL, following draft-ietf-nfsv4-acl-mapping like the
+// Linux NFS server. The mask is emulated with DENY ACEs, the entries of
+// the default ACL are appended as inherit only ACEs. def may be nil.
+// m defaults to NumericWhoMapper.
+func POSIXToNFS4(access, def *ACL, isDir bool, m NFS4WhoMapper) (*NFS4ACL, error) {
+	if m == nil {
+		m = NumericWhoMapper{}
+	}
+	result := &NFS4ACL{ACEs: []*NFS4ACE{}}
+	if err := posixToNFS4(result, access, 0, isDir, m); err != nil {
+		return nil, err
+	}
+	if def != nil && isDir && len(def.entries) > 0 {
+		if err := posixToNFS4(result, def, nfs4InheritFlags|NFS4_ACE_INHERIT_ONLY_ACE, isDir, m); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// posixSummary holds the permissions of the entry classes of an ACL,
+// the group class ones already masked
+type posixSummary struct {
+	owner, users, group, groups, other, mask uint16
+}
+
+// summarizePOSIX returns the posixSummary of entries
+func summarizePOSIX(entries []*ACLEntry) posixSummary {
+	s := posixSummary{mask: PermAll}
+	for _, e := range entries {
+		switch e.tag {
+		case TAG_ACL_USER_OBJ:
+			s.owner = e.perm
+		case TAG_ACL_USER:
+			s.users |= e.perm
+		case TAG_ACL_GROUP_OBJ:
+			s.group = e.perm
+		case TAG_ACL_GROUP:
+			s.groups |= e.perm
+		case TAG_ACL_MASK:
+			s.mask = e.perm
+		case TAG_ACL_OTHER:
+			s.other = e.perm
+		}
+	}
+	s.users &= s.mask
+	s.group &= s.mask
+	s.groups &= s.mask
+	return s
+}
+
+// posixToNFS4 appends the ACEs of a to result, with flags set on all of them
+func posixToNFS4(result *NFS4ACL, a *ACL, flags NFS4ACEFlag, isDir bool, m NFS4WhoMapper) error {
+	entries := a.sortedEntries()
+	s := summarizePOSIX(entries)
+	add := func(t NFS4ACEType, f NFS4ACEFlag, mask NFS4AccessMask, who string) {
+		result.ACEs = append(result.ACEs, &NFS4ACE{Type: t, Flags: flags | f, Mask: mask, Who: who})
+	}
+	named := func(e *ACLEntry) (string, error) {
+		who, err := m.Who(e.tag, e.id)
+		if err != nil {
+			return "", fmt.Errorf("mapping %s %d: %w", Tag2String(e.tag), e.id, err)
+		}
+		return who, nil
+	}
+	byTag := func(tag Tag) []*ACLEntry {
+		r := []*ACLEntry{}
+		for _, e := range entries {
+			if e.tag == tag {
+				r = append(r, e)
+			}
+		}
+		return r
+	}
+
+	// only deny what later ACEs would grant
+	if deny := ^s.owner & (s.users | s.group | s.groups | s.other) & PermAll; deny != 0 {
+		add(NFS4_ACE_ACCESS_DENIED_ACE_TYPE, 0, nfs4DenyMask(deny, isDir), NFS4WhoOwner)
+	}
+	add(NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, 0, nfs4AllowMask(s.owner, isDir, true), NFS4WhoOwner)
+
+	for _, e := range byTag(TAG_ACL_USER) {
+		who, err := named(e)
+		if err != nil {
+			return err
+		}
+		if deny := ^(e.perm & s.mask) & (s.groups | s.group | s.other) & PermAll; deny != 0 {
+			add(NFS4_ACE_ACCESS_DENIED_ACE_TYPE, 0, nfs4DenyMask(deny, isDir), who)
+		}
+		add(NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, 0, nfs4AllowMask(e.perm&s.mask, isDir, false), who)
+	}
+
+	// a user can be in several groups, so all groups
+	// are allowed first and denied afterwards
+	groups := byTag(TAG_ACL_GROUP)
+	groupWho := make([]string, len(groups))
+	add(NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, NFS4_ACE_IDENTIFIER_GROUP, nfs4AllowMask(s.group, isDir, false), NFS4WhoGroup)
+	for i, e := range groups {
+		who, err := named(e)
+		if err != nil {
+			return err
+		}
+		groupWho[i] = who
+		add(NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, NFS4_ACE_IDENTIFIER_GROUP, nfs4AllowMask(e.perm&s.mask, isDir, false), who)
+	}
+	if deny := ^s.group & s.other & PermAll; deny != 0 {
+		add(NFS4_ACE_ACCESS_DENIED_ACE_TYPE, NFS4_ACE_IDENTIFIER_GROUP, nfs4DenyMask(deny, isDir), NFS4WhoGroup)
+	}
+	for i, e := range groups {
+		if deny := ^(e.perm & s.mask) & s.other & PermAll; deny != 0 {
+			add(NFS4_ACE_ACCESS_DENIED_ACE_TYPE, NFS4_ACE_IDENTIFIER_GROUP, nfs4DenyMask(deny, isDir), groupWho[i])
+		}
+	}
+
+	add(NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, 0, nfs4AllowMask(s.other, isDir, false), NFS4WhoEveryone)
+	return nil
+}
+
+// nfs4DenyMask returns the access mask denying the POSIX permissions perm
+func nfs4DenyMask(perm uint16, isDir bool) NFS4AccessMask {
+	var mask NFS4AccessMask
+	if perm&PermRead != 0 {
+		mask |= nfs4ReadMode
+	}
+	if perm&PermWrite != 0 {
+		mask |= nfs4WriteMode
+		if isDir {
+			mask |= NFS4_ACE_DELETE_CHILD
+		}
+	}
+	if perm&PermExecute != 0 {
+		mask |= nfs4ExecuteMode
+	}
+	return mask
+}
+
+// nfs4AllowMask returns the access mask granting the POSIX permissions
+// perm, including what POSIX implicitly grants to everyone or the owner
+func nfs4AllowMask(perm uint16, isDir bool, owner bool) NFS4AccessMask {
+	mask := nfs4AnyoneMode | nfs4DenyMask(perm, isDir)
+	if owner {
+		mask |= nfs4OwnerMode
+	}
+	return mask
+}
+
+// posixPerm returns the POSIX permissions fully contained in mask
+func posixPerm(mask NFS4AccessMask, isDir bool) uint16 {
+	writeMode := nfs4WriteMode
+	if isDir {
+		writeMode |= NFS4_ACE_DELETE_CHILD
+	}
+	var perm uint16
+	if mask&nfs4ReadMode == nfs4ReadMode {
+		perm |= PermRead
+	}
+	if mask&writeMode == writeMode {
+		perm |= PermWrite
+	}
+	if mask&nfs4ExecuteMode == nfs4ExecuteMode {
+		perm |= PermExecute
+	}
+	return perm
+}
+
+// NFS4Loss describes an ACE that could not be converted exactly
+type NFS4Loss struct {
+	// Index is the position of the ACE within the NFSv4 ACL
+	Index  int
+	ACE    *NFS4ACE
+	Reason string
+}
+
+// String returns a human readable form of the NFS4Loss
+func (l *NFS4Loss) String() string {
+	return fmt.Sprintf("ace %d (%s): %s", l.Index, l.ACE, l.Reason)
+}
+
+// NFS4ToPOSIX converts an NFSv4 ACL to a POSIX access ACL and, for
+// directories, a default ACL built from the inheritable ACEs. def is
+// nil if there are none. ACEs are evaluated in order like the Linux
+// NFS server does, with DENY ACEs withholding permissions from later
+// ACEs. Everything that cannot be represented exactly is listed in the
+// returned losses, e.g. audit ACEs, unsupported flags or access mask
+// bits without POSIX equivalent. m defaults to NumericWhoMapper, ACEs
+// with principals it cannot map are skipped and reported as loss.
+func NFS4ToPOSIX(a *NFS4ACL, isDir bool, m NFS4WhoMapper) (access, def *ACL, losses []*NFS4Loss) {
+	if m == nil {
+		m = NumericWhoMapper{}
+	}
+	losses = []*NFS4Loss{}
+	lost := func(i int, e *NFS4ACE, format string, args ...any) {
+		losses = append(losses, &NFS4Loss{Index: i, ACE: e, Reason: fmt.Sprintf(format, args...)})
+	}
+	accessState, defaultState := &nfs4PosixState{}, &nfs4PosixState{}
+	hasDefault := false
+
+	for i, e := range a.ACEs {
+		if e.Type != NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE && e.Type != NFS4_ACE_ACCESS_DENIED_ACE_TYPE {
+			lost(i, e, "%s aces have no POSIX equivalent", e.Type)
+			continue
+		}
+		if f := e.Flags & (NFS4_ACE_NO_PROPAGATE_INHERIT_ACE | NFS4_ACE_SUCCESSFUL_ACCESS_ACE_FLAG | NFS4_ACE_FAILED_ACCESS_ACE_FLAG); f != 0 {
+			lost(i, e, "flags 0x%x ignored", uint32(f))
+		}
+		if unmapped := e.Mask &^ nfs4Representable(e); unmapped != 0 {
+			lost(i, e, "access mask bits 0x%x have no POSIX equivalent", uint32(unmapped))
+		}
+		if w := e.Mask & nfs4WriteMode; w != 0 && w != nfs4WriteMode {
+			lost(i, e, "write and append data can only be mapped together")
+		}
+
+		tag := e.Tag()
+		var id uint32
+		if tag == TAG_ACL_USER || tag == TAG_ACL_GROUP {
+			var err error
+			if id, err = m.ID(tag, e.Who); err != nil {
+				lost(i, e, "ace skipped: %v", err)
+				continue
+			}
+		}
+
+		inherit := e.Flags & nfs4InheritFlags
+		inheritOnly := e.Flags&NFS4_ACE_INHERIT_ONLY_ACE != 0
+		switch {
+		case inherit == 0 && inheritOnly:
+			lost(i, e, "ace skipped: inherit only without inheritance")
+			continue
+		case inherit != 0 && !isDir:
+			if inheritOnly {
+				lost(i, e, "ace skipped: inherit only on a non-directory")
+				continue
+			}
+			lost(i, e, "inheritance flags ignored on a non-directory")
+		case inherit != 0:
+			if inherit != nfs4InheritFlags {
+				lost(i, e, "inherited by both files and directories")
+			}
+			defaultState.process(tag, id, e)
+			hasDefault = true
+			if inheritOnly {
+				continue
+			}
+		}
+		accessState.process(tag, id, e)
+	}
+
+	access = accessState.acl(isDir)
+	if hasDefault {
+		def = defaultState.acl(isDir)
+	}
+	return access, def, losses
+}
+
+// nfs4Representable returns the access mask bits of e the POSIX
+// mapping can represent
+func nfs4Representable(e *NFS4ACE) NFS4AccessMask {
+	mask := nfs4ReadMode | nfs4WriteMode | nfs4ExecuteMode | NFS4_ACE_DELETE_CHILD | nfs4NamedAttrs
+	if e.Type == NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE {
+		mask |= nfs4AnyoneMode
+		if e.Who == NFS4WhoOwner {
+			mask |= nfs4OwnerMode
+		}
+	}
+	return mask
+}
+
+// nfs4Perms tracks the bits allowed and denied to a principal while
+// processing ACEs, the first ACE deciding about a bit wins
+type nfs4Perms struct {
+	allow, deny NFS4AccessMask
+}
+
+func (p *nfs4Perms) allowBits(m NFS4AccessMask) { p.allow |= m &^ p.deny }
+func (p *nfs4Perms) denyBits(m NFS4AccessMask)  { p.deny |= m &^ p.allow }
+
+// nfs4Principal is a named user or group with its nfs4Perms
+type nfs4Principal struct {
+	id    uint32
+	perms nfs4Perms
+}
+
+// nfs4PosixState is the state of the NFSv4 to POSIX conversion
+type nfs4PosixState struct {
+	owner, group, other, everyone nfs4Perms
+	users, groups                 []*nfs4Principal
+}
+
+// principal returns the named principal with id of list, adding it
+// with the permissions of everyone so far if it does not exist
+func (s *nfs4PosixState) principal(list *[]*nfs4Principal, id uint32) *nfs4Perms {
+	for _, p := range *list {
+		if p.id == id {
+			return &p.perms
+		}
+	}
+	p := &nfs4Principal{id: id, perms: s.everyone}
+	*list = append(*list, p)
+	return &p.perms
+}
+
+// process applies the ACE e for the principal tag and id to the state
+func (s *nfs4PosixState) process(tag Tag, id uint32, e *NFS4ACE) {
+	allow := e.Type == NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE
+	mask := e.Mask
+	// deny applies mask to all given perms
+	deny := func(mask NFS4AccessMask, perms ...*nfs4Perms) {
+		for _, p := range perms {
+			p.denyBits(mask)
+		}
+	}
+	denyNamed := func(mask NFS4AccessMask) {
+		for _, p := range s.users {
+			p.perms.denyBits(mask)
+		}
+		for _, p := range s.groups {
+			p.perms.denyBits(mask)
+		}
+	}
+
+	switch tag {
+	case TAG_ACL_USER_OBJ:
+		if allow {
+			s.owner.allowBits(mask)
+		} else {
+			s.owner.denyBits(mask)
+		}
+	case TAG_ACL_USER:
+		p := s.principal(&s.users, id)
+		if allow {
+			p.allowBits(mask)
+		} else {
+			p.denyBits(mask)
+			// the owner may be this user as well
+			deny(p.deny, &s.owner)
+		}
+	case TAG_ACL_GROUP_OBJ:
+		if allow {
+			s.group.allowBits(mask)
+		} else {
+			s.group.denyBits(mask)
+			deny(s.group.deny, &s.owner, &s.everyone)
+			denyNamed(s.group.deny)
+		}
+	case TAG_ACL_GROUP:
+		p := s.principal(&s.groups, id)
+		if allow {
+			p.allowBits(mask)
+		} else {
+			p.denyBits(mask)
+			denied := p.deny
+			deny(denied, &s.owner, &s.group, &s.everyone)
+			denyNamed(denied)
+		}
+	case TAG_ACL_EVERYONE:
+		all := []*nfs4Perms{&s.owner, &s.group, &s.other, &s.everyone}
+		for _, p := range s.users {
+			all = append(all, &p.perms)
+		}
+		for _, p := range s.groups {
+			all = append(all, &p.perms)
+		}
+		for _, p := range all {
+			if allow {
+				p.allowBits(mask)
+			} else {
+				p.denyBits(mask)
+			}
+		}
+	}
+}
+
+// acl returns the POSIX ACL of the state, with a mask covering the
+// group class if named entries exist
+func (s *nfs4PosixState) acl(isDir bool) *ACL {
+	result := NewACL()
+	var mask NFS4AccessMask
+	result.AddEntry(NewEntry(TAG_ACL_USER_OBJ, ACL_UNDEFINED_ID, posixPerm(s.owner.allow, isDir)))
+	for _, p := range s.users {
+		result.AddEntry(NewEntry(TAG_ACL_USER, p.id, posixPerm(p.perms.allow, isDir)))
+		mask |= p.perms.allow
+	}
+	result.AddEntry(NewEntry(TAG_ACL_GROUP_OBJ, ACL_UNDEFINED_ID, posixPerm(s.group.allow, isDir)))
+	mask |= s.group.allow
+	for _, p := range s.groups {
+		result.AddEntry(NewEntry(TAG_ACL_GROUP, p.id, posixPerm(p.perms.allow, isDir)))
+		mask |= p.perms.allow
+	}
+	if len(s.users) > 0 || len(s.groups) > 0 {
+		result.AddEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, posixPerm(mask, isDir)))
+	}
+	result.AddEntry(NewEntry(TAG_ACL_OTHER, ACL_UNDEFINED_ID, posixPerm(s.other.allow, isDir)))
+	return result
+}
@@ -0,0 +1,197 @@
+package acls
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPOSIXToNFS4(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	rwOwner := nfs4AnyoneMode | nfs4OwnerMode | nfs4ReadMode | nfs4WriteMode
+	tests := []struct {
+		name   string
+		access []*ACLEntry
+		def    []*ACLEntry
+		isDir  bool
+		want   []*NFS4ACE
+	}{
+		{
+			name: "minimal 0640",
+			access: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 6),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+			want: []*NFS4ACE{
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: rwOwner, Who: NFS4WhoOwner},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4AnyoneMode | nfs4ReadMode, Who: NFS4WhoGroup},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: nfs4AnyoneMode, Who: NFS4WhoEveryone},
+			},
+		},
+		{
+			name: "mask emulated with deny aces",
+			access: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 6),
+				NewEntry(TAG_ACL_USER, 1000, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 0),
+				NewEntry(TAG_ACL_GROUP, 2000, 6),
+				NewEntry(TAG_ACL_MASK, u, 6),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+			want: []*NFS4ACE{
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: rwOwner, Who: NFS4WhoOwner},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: nfs4AnyoneMode | nfs4ReadMode | nfs4WriteMode, Who: "1000"},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4AnyoneMode, Who: NFS4WhoGroup},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4AnyoneMode | nfs4ReadMode | nfs4WriteMode, Who: "2000"},
+				{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4ReadMode, Who: NFS4WhoGroup},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: nfs4AnyoneMode | nfs4ReadMode, Who: NFS4WhoEveryone},
+			},
+		},
+		{
+			name:  "default acl inherited",
+			isDir: true,
+			access: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 0),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 0),
+				NewEntry(TAG_ACL_OTHER, u, 1),
+			},
+			def: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 2),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 0),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+			want: []*NFS4ACE{
+				{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE, Mask: nfs4ExecuteMode, Who: NFS4WhoOwner},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: nfs4AnyoneMode | nfs4OwnerMode, Who: NFS4WhoOwner},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4AnyoneMode, Who: NFS4WhoGroup},
+				{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4ExecuteMode, Who: NFS4WhoGroup},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: nfs4AnyoneMode | nfs4ExecuteMode, Who: NFS4WhoEveryone},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: 0xb, Mask: nfs4AnyoneMode | nfs4OwnerMode | nfs4WriteMode | NFS4_ACE_DELETE_CHILD, Who: NFS4WhoOwner},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: 0x4b, Mask: nfs4AnyoneMode, Who: NFS4WhoGroup},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: 0xb, Mask: nfs4AnyoneMode, Who: NFS4WhoEveryone},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := &ACL{version: 2, entries: tt.access}
+			var def *ACL
+			if tt.def != nil {
+				def = &ACL{version: 2, entries: tt.def}
+			}
+			got, err := POSIXToNFS4(access, def, tt.isDir, nil)
+			if err != nil {
+				t.Fatalf("POSIXToNFS4() unexpected error %v", err)
+			}
+			want := &NFS4ACL{ACEs: tt.want}
+			if !got.Equal(want) {
+				t.Errorf("POSIXToNFS4() = %v, want %v", got, want)
+			}
+
+			// converting back preserves the effective permissions
+			back, backDef, losses := NFS4ToPOSIX(got, tt.isDir, nil)
+			if len(losses) != 0 {
+				t.Errorf("NFS4ToPOSIX() unexpected losses %v", losses)
+			}
+			if !newCredentialUniverse(access).equivalent(access, back) {
+				t.Errorf("NFS4ToPOSIX() = %v, not equivalent to %v", back, access)
+			}
+			if (def == nil) != (backDef == nil) || def != nil && !newCredentialUniverse(def).equivalent(def, backDef) {
+				t.Errorf("NFS4ToPOSIX() default = %v, not equivalent to %v", backDef, def)
+			}
+		})
+	}
+}
+
+func TestNFS4ToPOSIX(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	rwx := nfs4ReadMode | nfs4WriteMode | nfs4ExecuteMode
+	tests := []struct {
+		name   string
+		aces   []*NFS4ACE
+		isDir  bool
+		want   []*ACLEntry
+		def    []*ACLEntry
+		losses []string
+	}{
+		{
+			name: "deny before allow withholds permissions",
+			aces: []*NFS4ACE{
+				{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4WriteMode, Who: "2000"},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: rwx, Who: NFS4WhoEveryone},
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 2000, 5),
+				NewEntry(TAG_ACL_MASK, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 7),
+			},
+		},
+		{
+			name:  "inheritance",
+			isDir: true,
+			aces: []*NFS4ACE{
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: rwx | NFS4_ACE_DELETE_CHILD, Who: NFS4WhoOwner},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: NFS4_ACE_FILE_INHERIT_ACE | NFS4_ACE_DIRECTORY_INHERIT_ACE | NFS4_ACE_INHERIT_ONLY_ACE, Mask: nfs4ReadMode, Who: "1000"},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: NFS4_ACE_FILE_INHERIT_ACE, Mask: nfs4ReadMode, Who: NFS4WhoEveryone},
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+			def: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 4),
+				NewEntry(TAG_ACL_USER, 1000, 4),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_MASK, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 4),
+			},
+			losses: []string{"inherited by both files and directories"},
+		},
+		{
+			name: "lossy aces",
+			aces: []*NFS4ACE{
+				{Type: NFS4_ACE_SYSTEM_AUDIT_ACE_TYPE, Flags: NFS4_ACE_FAILED_ACCESS_ACE_FLAG, Mask: rwx, Who: NFS4WhoEveryone},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: nfs4ReadMode | NFS4_ACE_DELETE | NFS4_ACE_WRITE_ACL, Who: NFS4WhoGroup},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: NFS4_ACE_APPEND_DATA, Who: NFS4WhoOwner},
+				{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: nfs4ReadMode, Who: "alice@example.com"},
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 0),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+			losses: []string{
+				"AUDIT aces have no POSIX equivalent",
+				"access mask bits 0x50000 have no POSIX equivalent",
+				"write and append data can only be mapped together",
+				"ace skipped: principal \"alice@example.com\" is not a numeric id",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, def, losses := NFS4ToPOSIX(&NFS4ACL{ACEs: tt.aces}, tt.isDir, nil)
+			if want := (&ACL{version: 2, entries: tt.want}); !access.Equal(want) {
+				t.Errorf("NFS4ToPOSIX() access = %v, want %v", access, want)
+			}
+			if tt.def == nil && def != nil {
+				t.Errorf("NFS4ToPOSIX() unexpected default %v", def)
+			}
+			if want := (&ACL{version: 2, entries: tt.def}); tt.def != nil && (def == nil || !def.Equal(want)) {
+				t.Errorf("NFS4ToPOSIX() default = %v, want %v", def, want)
+			}
+			if len(losses) != len(tt.losses) {
+				t.Fatalf("NFS4ToPOSIX() losses = %v, want %v", losses, tt.losses)
+			}
+			for i, l := range losses {
+				if !strings.Contains(l.String(), tt.losses[i]) {
+					t.Errorf("loss %d = %s, want %s", i, l, tt.losses[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package acls
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nfs4TypeLetters are the ACE type letters of the nfs4-acl-tools text format
+var nfs4TypeLetters = []struct {
+	letter byte
+	t      NFS4ACEType
+}{
+	{'A', NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE},
+	{'D', NFS4_ACE_ACCESS_DENIED_ACE_TYPE},
+	{'U', NFS4_ACE_SYSTEM_AUDIT_ACE_TYPE},
+	{'L', NFS4_ACE_SYSTEM_ALARM_ACE_TYPE},
+}
+
+// nfs4FlagLetters are the flag letters in the order nfs4_getfacl prints them
+var nfs4FlagLetters = []struct {
+	letter byte
+	flag   NFS4ACEFlag
+}{
+	{'f', NFS4_ACE_FILE_INHERIT_ACE},
+	{'d', NFS4_ACE_DIRECTORY_INHERIT_ACE},
+	{'n', NFS4_ACE_NO_PROPAGATE_INHERIT_ACE},
+	{'i', NFS4_ACE_INHERIT_ONLY_ACE},
+	{'S', NFS4_ACE_SUCCESSFUL_ACCESS_ACE_FLAG},
+	{'F', NFS4_ACE_FAILED_ACCESS_ACE_FLAG},
+	{'g', NFS4_ACE_IDENTIFIER_GROUP},
+	{'I', NFS4_ACE_INHERITED_ACE},
+}
+
+// nfs4PermLetters are the permission letters in the order nfs4_getfacl prints them
+var nfs4PermLetters = []struct {
+	letter byte
+	mask   NFS4AccessMask
+}{
+	{'r', NFS4_ACE_READ_DATA},
+	{'w', NFS4_ACE_WRITE_DATA},
+	{'a', NFS4_ACE_APPEND_DATA},
+	{'D', NFS4_ACE_DELETE_CHILD},
+	{'d', NFS4_ACE_DELETE},
+	{'x', NFS4_ACE_EXECUTE},
+	{'t', NFS4_ACE_READ_ATTRIBUTES},
+	{'T', NFS4_ACE_WRITE_ATTRIBUTES},
+	{'n', NFS4_ACE_READ_NAMED_ATTRS},
+	{'N', NFS4_ACE_WRITE_NAMED_ATTRS},
+	{'c', NFS4_ACE_READ_ACL},
+	{'C', NFS4_ACE_WRITE_ACL},
+	{'o', NFS4_ACE_WRITE_OWNER},
+	{'y', NFS4_ACE_SYNCHRONIZE},
+}
+
+// nfs4PermAliases are the generic permission letters accepted by
+// nfs4_setfacl. W additionally grants D on directories.
+var nfs4PermAliases = map[byte]string{
+	'R': "rntcy",
+	'W': "watTNcCy",
+	'X': "xtcy",
+}
+
+// Text returns the ACE in the text format of nfs4_getfacl,
+// e.g. "A:fdg:staff@example.com:rxtncy". Access mask bits
+// without letter, like the retention bits, are left out.
+func (e *NFS4ACE) Text() string {
+	sb := &strings.Builder{}
+	typeWritten := false
+	for _, l := range nfs4TypeLetters {
+		if l.t == e.Type {
+			sb.WriteByte(l.letter)
+			typeWritten = true
+		}
+	}
+	if !typeWritten {
+		fmt.Fprintf(sb, "%d", uint32(e.Type))
+	}
+	sb.WriteByte(':')
+	for _, l := range nfs4FlagLetters {
+		if e.Flags&l.flag != 0 {
+			sb.WriteByte(l.letter)
+		}
+	}
+	sb.WriteByte(':')
+	sb.WriteString(e.Who)
+	sb.WriteByte(':')
+	for _, l := range nfs4PermLetters {
+		if e.Mask&l.mask != 0 {
+			sb.WriteByte(l.letter)
+		}
+	}
+	return sb.String()
+}
+
+// ParseNFS4ACE parses an ACE in the text format of nfs4_setfacl,
+// type:flags:principal:permissions. Permissions may use the aliases
+// R, W and X, with W including D if isDir is set.
+func ParseNFS4ACE(s string, isDir bool) (*NFS4ACE, error) {
+	fields := strings.Split(strings.TrimSpace(s), ":")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid nfs4 ace %q: expected type:flags:principal:permissions", s)
+	}
+	e := &NFS4ACE{Who: fields[2]}
+	if e.Who == "" {
+		return nil, fmt.Errorf("invalid nfs4 ace %q: empty principal", s)
+	}
+
+	if len(fields[0]) != 1 {
+		return nil, fmt.Errorf("invalid nfs4 ace %q: unknown type %q", s, fields[0])
+	}
+	found := false
+	for _, l := range nfs4TypeLetters {
+		if l.letter == fields[0][0] {
+			e.Type = l.t
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid nfs4 ace %q: unknown type %q", s, fields[0])
+	}
+
+	for _, c := range []byte(fields[1]) {
+		found := false
+		for _, l := range nfs4FlagLetters {
+			if l.letter == c {
+				e.Flags |= l.flag
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid nfs4 ace %q: unknown flag %q", s, c)
+		}
+	}
+
+	perms := fields[3]
+	for alias, expanded := range nfs4PermAliases {
+		if strings.IndexByte(perms, alias) < 0 {
+			continue
+		}
+		if alias == 'W' && isDir {
+			expanded += "D"
+		}
+		perms = strings.ReplaceAll(perms, string(alias), expanded)
+	}
+	for _, c := range []byte(perms) {
+		found := false
+		for _, l := range nfs4PermLetters {
+			if l.letter == c {
+				e.Mask |= l.mask
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid nfs4 ace %q: unknown permission %q", s, c)
+		}
+	}
+	return e, nil
+}
+
+// Text returns the ACL in the text format of nfs4_getfacl, one ACE per line
+func (a *NFS4ACL) Text() string {
+	sb := &strings.Builder{}
+	for _, e := range a.ACEs {
+		sb.WriteString(e.Text())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// ParseNFS4ACL parses an ACL in the text format of nfs4_getfacl and
+// nfs4_setfacl. ACEs are separated by newlines or commas, empty lines
+// and lines starting with # are ignored.
+func ParseNFS4ACL(s string, isDir bool) (*NFS4ACL, error) {
+	result := &NFS4ACL{ACEs: []*NFS4ACE{}}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, spec := range strings.Split(line, ",") {
+			e, err := ParseNFS4ACE(spec, isDir)
+			if err != nil {
+				return nil, err
+			}
+			result.ACEs = append(result.ACEs, e)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+package acls
+
+import "testing"
+
+func TestParseNFS4ACE(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		isDir   bool
+		want    *NFS4ACE
+		text    string
+		wantErr bool
+	}{
+		{
+			name:  "owner",
+			input: "A::OWNER@:rwatTnNcCy",
+			want:  &NFS4ACE{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: 0x1601bf &^ NFS4_ACE_EXECUTE, Who: NFS4WhoOwner},
+			text:  "A::OWNER@:rwatTnNcCy",
+		},
+		{
+			name:  "named group with inheritance",
+			input: "D:fdig:staff@example.com:wadD",
+			want: &NFS4ACE{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE,
+				Flags: NFS4_ACE_FILE_INHERIT_ACE | NFS4_ACE_DIRECTORY_INHERIT_ACE | NFS4_ACE_INHERIT_ONLY_ACE | NFS4_ACE_IDENTIFIER_GROUP,
+				Mask:  NFS4_ACE_WRITE_DATA | NFS4_ACE_APPEND_DATA | NFS4_ACE_DELETE | NFS4_ACE_DELETE_CHILD, Who: "staff@example.com"},
+			text: "D:fdig:staff@example.com:waDd",
+		},
+		{
+			name:  "audit",
+			input: "U:SF:EVERYONE@:C",
+			want:  &NFS4ACE{Type: NFS4_ACE_SYSTEM_AUDIT_ACE_TYPE, Flags: NFS4_ACE_SUCCESSFUL_ACCESS_ACE_FLAG | NFS4_ACE_FAILED_ACCESS_ACE_FLAG, Mask: NFS4_ACE_WRITE_ACL, Who: NFS4WhoEveryone},
+			text:  "U:SF:EVERYONE@:C",
+		},
+		{
+			name:  "aliases on file",
+			input: "A::1000:RWX",
+			want:  &NFS4ACE{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: 0x1601bf, Who: "1000"},
+			text:  "A::1000:rwaxtTnNcCy",
+		},
+		{
+			name:  "write alias on directory",
+			input: "A::1000:W",
+			isDir: true,
+			want:  &NFS4ACE{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Mask: 0x160196 | NFS4_ACE_DELETE_CHILD, Who: "1000"},
+			text:  "A::1000:waDtTNcCy",
+		},
+		{name: "missing field", input: "A::OWNER@", wantErr: true},
+		{name: "unknown type", input: "Z::OWNER@:r", wantErr: true},
+		{name: "unknown flag", input: "A:q:OWNER@:r", wantErr: true},
+		{name: "unknown permission", input: "A::OWNER@:rq", wantErr: true},
+		{name: "empty principal", input: "A:::r", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNFS4ACE(tt.input, tt.isDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNFS4ACE() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *got != *tt.want {
+				t.Errorf("ParseNFS4ACE() = %v, want %v", got, tt.want)
+			}
+			if text := got.Text(); text != tt.text {
+				t.Errorf("Text() = %s, want %s", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestNFS4ACL_TextRoundTrip(t *testing.T) {
+	input := `# file: /mnt/nfs/dir
+A::OWNER@:rwaDxtTnNcCy
+D:g:GROUP@:waD
+A:g:GROUP@:rxtncy
+A:fdi:alice@example.com:rwaDxtTnNcCy
+A::EVERYONE@:rxtncy
+`
+	a, err := ParseNFS4ACL(input, true)
+	if err != nil {
+		t.Fatalf("ParseNFS4ACL() unexpected error %v", err)
+	}
+	if len(a.ACEs) != 5 {
+		t.Fatalf("expected 5 aces, got %d", len(a.ACEs))
+	}
+	want := input[len("# file: /mnt/nfs/dir\n"):]
+	if got := a.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+
+	b, err := ParseNFS4ACL(a.Text(), true)
+	if err != nil || !b.Equal(a) {
+		t.Errorf("round trip = %v (%v), want %v", b, err, a)
+	}
+
+	c, err := ParseNFS4ACL("A::OWNER@:rwaDxtTnNcCy,A:g:GROUP@:rxtncy, A::EVERYONE@:rxtncy", true)
+	if err != nil || len(c.ACEs) != 3 {
+		t.Errorf("comma separated = %v (%v)", c, err)
+	}
+}
@@ -0,0 +1,262 @@
+package acls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NTACEType is the type of a Windows ACE
+type NTACEType uint8
+
+// Windows ACE types
+const (
+	NT_ACCESS_ALLOWED_ACE_TYPE NTACEType = 0x0
+	NT_ACCESS_DENIED_ACE_TYPE  NTACEType = 0x1
+)
+
+// Windows ACE flags
+const (
+	NT_OBJECT_INHERIT_ACE       uint8 = 0x01
+	NT_CONTAINER_INHERIT_ACE    uint8 = 0x02
+	NT_NO_PROPAGATE_INHERIT_ACE uint8 = 0x04
+	NT_INHERIT_ONLY_ACE         uint8 = 0x08
+	NT_INHERITED_ACE            uint8 = 0x10
+)
+
+// Windows file access rights used by the POSIX mapping
+const (
+	NT_FILE_READ_DATA       uint32 = 0x00000001
+	NT_FILE_WRITE_DATA      uint32 = 0x00000002
+	NT_FILE_APPEND_DATA     uint32 = 0x00000004
+	NT_FILE_READ_EA         uint32 = 0x00000008
+	NT_FILE_WRITE_EA        uint32 = 0x00000010
+	NT_FILE_EXECUTE         uint32 = 0x00000020
+	NT_FILE_GENERIC_READ    uint32 = 0x00120089
+	NT_FILE_GENERIC_WRITE   uint32 = 0x00120116
+	NT_FILE_GENERIC_EXECUTE uint32 = 0x001200a0
+	NT_FILE_ALL_ACCESS      uint32 = 0x001f01ff
+	NT_GENERIC_ALL          uint32 = 0x10000000
+	NT_GENERIC_EXECUTE      uint32 = 0x20000000
+	NT_GENERIC_WRITE        uint32 = 0x40000000
+	NT_GENERIC_READ         uint32 = 0x80000000
+)
+
+// Well-known SIDs
+const (
+	SIDEveryone     = "S-1-1-0"
+	SIDCreatorOwner = "S-1-3-0"
+	SIDCreatorGroup = "S-1-3-1"
+)
+
+// ntACEFlagNames are the SDDL names of the ACE flags in output order
+var ntACEFlagNames = []struct {
+	name string
+	flag uint8
+}{
+	{"OI", NT_OBJECT_INHERIT_ACE},
+	{"CI", NT_CONTAINER_INHERIT_ACE},
+	{"NP", NT_NO_PROPAGATE_INHERIT_ACE},
+	{"IO", NT_INHERIT_ONLY_ACE},
+	{"ID", NT_INHERITED_ACE},
+}
+
+// ntRightNames are the SDDL names of access rights accepted by ParseSDDL
+var ntRightNames = map[string]uint32{
+	"FA": NT_FILE_ALL_ACCESS,
+	"FR": NT_FILE_GENERIC_READ,
+	"FW": NT_FILE_GENERIC_WRITE,
+	"FX": NT_FILE_GENERIC_EXECUTE,
+	"GA": NT_GENERIC_ALL,
+	"GR": NT_GENERIC_READ,
+	"GW": NT_GENERIC_WRITE,
+	"GX": NT_GENERIC_EXECUTE,
+}
+
+// ntSIDAliases are the SDDL SID abbreviations accepted by ParseSDDL
+var ntSIDAliases = map[string]string{
+	"WD": SIDEveryone,
+	"CO": SIDCreatorOwner,
+	"CG": SIDCreatorGroup,
+}
+
+// NTACE is an access control entry of a Windows DACL
+type NTACE struct {
+	Type  NTACEType
+	Flags uint8
+	Mask  uint32
+	SID   string
+}
+
+// SDDL returns the ACE in SDDL form, e.g. "(A;OICI;0x1f01ff;;;S-1-1-0)"
+func (e *NTACE) SDDL() string {
+	t := "A"
+	if e.Type == NT_ACCESS_DENIED_ACE_TYPE {
+		t = "D"
+	}
+	flags := ""
+	for _, f := range ntACEFlagNames {
+		if e.Flags&f.flag != 0 {
+			flags += f.name
+		}
+	}
+	return fmt.Sprintf("(%s;%s;0x%x;;;%s)", t, flags, e.Mask, e.SID)
+}
+
+// SecurityDescriptor is the owner, group and DACL of a file as
+// presented to Windows clients
+type SecurityDescriptor struct {
+	Owner string
+	Group string
+	// DACLFlags are the SDDL flags of the DACL, like "P" or "AI"
+	DACLFlags string
+	DACL      []*NTACE
+}
+
+// SDDL returns the security descriptor in SDDL form
+func (sd *SecurityDescriptor) SDDL() string {
+	sb := &strings.Builder{}
+	if sd.Owner != "" {
+		sb.WriteString("O:" + sd.Owner)
+	}
+	if sd.Group != "" {
+		sb.WriteString("G:" + sd.Group)
+	}
+	sb.WriteString("D:" + sd.DACLFlags)
+	for _, e := range sd.DACL {
+		sb.WriteString(e.SDDL())
+	}
+	return sb.String()
+}
+
+// ParseSDDL parses the owner, group and DACL of a security descriptor
+// in SDDL form. Access rights may be given as hex number or as file and
+// generic right abbreviations like FR or GA, SIDs as string or as the
+// abbreviations WD, CO and CG. SACLs and object ACEs are not supported.
+func ParseSDDL(s string) (*SecurityDescriptor, error) {
+	sd := &SecurityDescriptor{DACL: []*NTACE{}}
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		if len(rest) < 2 || rest[1] != ':' {
+			return nil, fmt.Errorf("invalid sddl %q: expected section at %q", s, rest)
+		}
+		section := rest[0]
+		rest = rest[2:]
+		// a section ends where the next one starts
+		end := len(rest)
+		for i := 0; i+1 < len(rest); i++ {
+			if rest[i+1] == ':' && strings.IndexByte("OGDS", rest[i]) >= 0 && !insideParens(rest[:i]) {
+				end = i
+				break
+			}
+		}
+		value := rest[:end]
+		rest = rest[end:]
+		switch section {
+		case 'O':
+			sd.Owner = ntSID(value)
+		case 'G':
+			sd.Group = ntSID(value)
+		case 'D':
+			if err := sd.parseDACL(value); err != nil {
+				return nil, fmt.Errorf("invalid sddl %q: %w", s, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid sddl %q: unsupported section %c", s, section)
+		}
+	}
+	return sd, nil
+}
+
+// insideParens reports whether s ends within an unclosed parenthesis
+func insideParens(s string) bool {
+	return strings.Count(s, "(") > strings.Count(s, ")")
+}
+
+// ntSID resolves SDDL SID abbreviations
+func ntSID(s string) string {
+	if sid, ok := ntSIDAliases[s]; ok {
+		return sid
+	}
+	return s
+}
+
+// parseDACL parses the flags and ACEs of the D: section
+func (sd *SecurityDescriptor) parseDACL(s string) error {
+	start := strings.IndexByte(s, '(')
+	if start < 0 {
+		start = len(s)
+	}
+	sd.DACLFlags = s[:start]
+	for rest := s[start:]; rest != ""; {
+		if rest[0] != '(' {
+			return fmt.Errorf("expected ace at %q", rest)
+		}
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return fmt.Errorf("unterminated ace %q", rest)
+		}
+		e, err := parseNTACE(rest[1:end])
+		if err != nil {
+			return err
+		}
+		sd.DACL = append(sd.DACL, e)
+		rest = rest[end+1:]
+	}
+	return nil
+}
+
+// parseNTACE parses the content of an SDDL ACE without the parentheses
+func parseNTACE(s string) (*NTACE, error) {
+	fields := strings.Split(s, ";")
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("invalid ace %q: expected 6 fields", s)
+	}
+	e := &NTACE{SID: ntSID(fields[5])}
+	switch fields[0] {
+	case "A":
+		e.Type = NT_ACCESS_ALLOWED_ACE_TYPE
+	case "D":
+		e.Type = NT_ACCESS_DENIED_ACE_TYPE
+	default:
+		return nil, fmt.Errorf("invalid ace %q: unsupported type %q", s, fields[0])
+	}
+	if fields[3] != "" || fields[4] != "" {
+		return nil, fmt.Errorf("invalid ace %q: object aces are not supported", s)
+	}
+	for flags := fields[1]; flags != ""; flags = flags[2:] {
+		found := false
+		for _, f := range ntACEFlagNames {
+			if strings.HasPrefix(flags, f.name) {
+				e.Flags |= f.flag
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid ace %q: unknown flags %q", s, flags)
+		}
+	}
+	rights := fields[2]
+	if strings.HasPrefix(rights, "0x") || strings.HasPrefix(rights, "0X") {
+		v, err := strconv.ParseUint(rights[2:], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ace %q: %w", s, err)
+		}
+		e.Mask = uint32(v)
+	} else {
+		for ; rights != ""; rights = rights[2:] {
+			if len(rights) < 2 {
+				return nil, fmt.Errorf("invalid ace %q: unknown rights %q", s, rights)
+			}
+			v, ok := ntRightNames[rights[:2]]
+			if !ok {
+				return nil, fmt.Errorf("invalid ace %q: unknown rights %q", s, rights[:2])
+			}
+			e.Mask |= v
+		}
+	}
+	if e.SID == "" {
+		return nil, fmt.Errorf("invalid ace %q: empty sid", s)
+	}
+	return e, nil
+}
@@ -0,0 +1,56 @@
+package acls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSDDL(t *testing.T) {
+	tests := []struct {
+		name    string
+		sddl    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "round trip",
+			sddl: "O:S-1-22-1-0G:S-1-22-2-100D:P(A;;0x1f01ff;;;S-1-22-1-0)(A;OICIIO;0x1200a9;;;S-1-3-1)",
+			want: "O:S-1-22-1-0G:S-1-22-2-100D:P(A;;0x1f01ff;;;S-1-22-1-0)(A;OICIIO;0x1200a9;;;S-1-3-1)",
+		},
+		{
+			name: "aliases",
+			sddl: "O:S-1-22-1-0G:S-1-22-2-100D:(A;CIOI;FA;;;CO)(D;;FW;;;WD)(A;IO;GR;;;CG)",
+			want: "O:S-1-22-1-0G:S-1-22-2-100D:(A;OICI;0x1f01ff;;;S-1-3-0)(D;;0x120116;;;S-1-1-0)(A;IO;0x80000000;;;S-1-3-1)",
+		},
+		{
+			name: "dacl only",
+			sddl: "D:(A;;0x1200a9;;;S-1-1-0)",
+			want: "D:(A;;0x1200a9;;;S-1-1-0)",
+		},
+		{name: "sacl", sddl: "D:S:(AU;SA;FA;;;WD)", wantErr: "unsupported section"},
+		{name: "object ace", sddl: "D:(OA;;FA;guid;;WD)", wantErr: "unsupported type"},
+		{name: "object guid", sddl: "D:(A;;FA;guid;;WD)", wantErr: "object aces"},
+		{name: "unknown flags", sddl: "D:(A;XX;FA;;;WD)", wantErr: "unknown flags"},
+		{name: "unknown rights", sddl: "D:(A;;QQ;;;WD)", wantErr: "rights"},
+		{name: "unterminated", sddl: "D:(A;;FA;;;WD", wantErr: "unterminated"},
+		{name: "fields", sddl: "D:(A;;FA;WD)", wantErr: "6 fields"},
+		{name: "no section", sddl: "S-1-1-0", wantErr: "expected section"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd, err := ParseSDDL(tt.sddl)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSDDL() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSDDL() error = %v", err)
+			}
+			if got := sd.SDDL(); got != tt.want {
+				t.Errorf("SDDL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package acls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SIDMapper translates between POSIX qualifiers and Windows SIDs
+type SIDMapper interface {
+	// SID returns the SID of a uid for TAG_ACL_USER or a gid for TAG_ACL_GROUP
+	SID(tag Tag, id uint32) (string, error)
+	// ID returns the tag, TAG_ACL_USER or TAG_ACL_GROUP, and the
+	// uid or gid of sid. ok is false for SIDs it cannot map.
+	ID(sid string) (tag Tag, id uint32, ok bool)
+}
+
+// Unix SID prefixes Samba uses for unmapped uids and gids
+const (
+	unixUserSIDPrefix  = "S-1-22-1-"
+	unixGroupSIDPrefix = "S-1-22-2-"
+)
+
+// UnixSIDMapper maps uids to S-1-22-1-<uid> and gids to
+// S-1-22-2-<gid>, the Unix SIDs used by Samba
+type UnixSIDMapper struct{}
+
+// SID returns the Unix SID of the uid or gid
+func (UnixSIDMapper) SID(tag Tag, id uint32) (string, error) {
+	if tag == TAG_ACL_GROUP {
+		return unixGroupSIDPrefix + strconv.FormatUint(uint64(id), 10), nil
+	}
+	return unixUserSIDPrefix + strconv.FormatUint(uint64(id), 10), nil
+}
+
+// ID parses a Unix SID
+func (UnixSIDMapper) ID(sid string) (Tag, uint32, bool) {
+	tag, rest := Tag(TAG_ACL_USER), ""
+	switch {
+	case strings.HasPrefix(sid, unixUserSIDPrefix):
+		rest = sid[len(unixUserSIDPrefix):]
+	case strings.HasPrefix(sid, unixGroupSIDPrefix):
+		tag, rest = TAG_ACL_GROUP, sid[len(unixGroupSIDPrefix):]
+	default:
+		return 0, 0, false
+	}
+	id, err := strconv.ParseUint(rest, 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return tag, uint32(id), true
+}
+
+// ntInheritFlags mark ACEs inherited by files and directories
+const ntInheritFlags = NT_OBJECT_INHERIT_ACE | NT_CONTAINER_INHERIT_ACE
+
+// POSIXToNT converts the access ACL and, for directories, the default
+// ACL of a file owned by owner and group to the security descriptor
+// Samba presents to Windows clients. Every entry becomes an allow ACE
+// with the mask applied to the group class and OTHER mapped to
+// Everyone. Entries of the default ACL become inherit only ACEs, with
+// the owner entries mapped to Creator Owner and Creator Group, and are
+// merged into the access ACE of the same SID and rights. def may be
+// nil, m defaults to UnixSIDMapper.
+func POSIXToNT(access, def *ACL, owner, group uint32, isDir bool, m SIDMapper) (*SecurityDescriptor, error) {
+	if m == nil {
+		m = UnixSIDMapper{}
+	}
+	ownerSID, err := m.SID(TAG_ACL_USER, owner)
+	if err != nil {
+		return nil, err
+	}
+	groupSID, err := m.SID(TAG_ACL_GROUP, group)
+	if err != nil {
+		return nil, err
+	}
+	sd := &SecurityDescriptor{Owner: ownerSID, Group: groupSID, DACL: []*NTACE{}}
+	aces, err := posixToNTACEs(access, ownerSID, groupSID, 0, isDir, m)
+	if err != nil {
+		return nil, err
+	}
+	sd.DACL = aces
+	if def == nil || !isDir || len(def.entries) == 0 {
+		return sd, nil
+	}
+
+	aces, err = posixToNTACEs(def, SIDCreatorOwner, SIDCreatorGroup, ntInheritFlags|NT_INHERIT_ONLY_ACE, isDir, m)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range aces {
+		merged := false
+		for _, a := range sd.DACL {
+			if a.Flags == 0 && a.SID == d.SID && a.Mask == d.Mask {
+				a.Flags = ntInheritFlags
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			sd.DACL = append(sd.DACL, d)
+		}
+	}
+	return sd, nil
+}
+
+// posixToNTACEs returns the allow ACEs of a with the given flags
+func posixToNTACEs(a *ACL, ownerSID, groupSID string, flags uint8, isDir bool, m SIDMapper) ([]*NTACE, error) {
+	entries := a.sortedEntries()
+	mask := PermAll
+	for _, e := range entries {
+		if e.tag == TAG_ACL_MASK {
+			mask = e.perm
+		}
+	}
+	result := []*NTACE{}
+	for _, e := range entries {
+		perm := e.perm
+		var sid string
+		switch e.tag {
+		case TAG_ACL_USER_OBJ:
+			sid = ownerSID
+		case TAG_ACL_GROUP_OBJ:
+			sid, perm = groupSID, perm&mask
+		case TAG_ACL_USER, TAG_ACL_GROUP:
+			var err error
+			if sid, err = m.SID(e.tag, e.id); err != nil {
+				return nil, fmt.Errorf("mapping %s %d: %w", Tag2String(e.tag), e.id, err)
+			}
+			perm &= mask
+		case TAG_ACL_OTHER:
+			sid = SIDEveryone
+		default:
+			continue
+		}
+		result = append(result, &NTACE{Type: NT_ACCESS_ALLOWED_ACE_TYPE, Flags: flags, Mask: ntMask(perm), SID: sid})
+	}
+	return result, nil
+}
+
+// ntMask returns the access rights Samba maps the POSIX permissions to
+func ntMask(perm uint16) uint32 {
+	switch perm {
+	case PermAll:
+		return NT_FILE_ALL_ACCESS
+	case PermNone:
+		return 0
+	}
+	var mask uint32
+	if perm&PermRead != 0 {
+		mask |= NT_FILE_GENERIC_READ
+	}
+	if perm&PermWrite != 0 {
+		mask |= NT_FILE_GENERIC_WRITE
+	}
+	if perm&PermExecute != 0 {
+		mask |= NT_FILE_GENERIC_EXECUTE
+	}
+	return mask
+}
+
+// posixFromNTMask returns the POSIX permissions Samba maps the access rights to
+func posixFromNTMask(mask uint32) uint16 {
+	if mask&NT_GENERIC_ALL != 0 || mask&NT_FILE_ALL_ACCESS == NT_FILE_ALL_ACCESS {
+		return PermAll
+	}
+	var perm uint16
+	if mask&(NT_GENERIC_READ|NT_FILE_READ_DATA|NT_FILE_READ_EA) != 0 {
+		perm |= PermRead
+	}
+	if mask&(NT_GENERIC_WRITE|NT_FILE_WRITE_DATA|NT_FILE_APPEND_DATA|NT_FILE_WRITE_EA) != 0 {
+		perm |= PermWrite
+	}
+	if mask&(NT_GENERIC_EXECUTE|NT_FILE_EXECUTE) != 0 {
+		perm |= PermExecute
+	}
+	return perm
+}
+
+// NTLoss describes an ACE that could not be converted exactly
+type NTLoss struct {
+	// Index is the position of the ACE within the DACL
+	Index  int
+	ACE    *NTACE
+	Reason string
+}
+
+// String returns a human readable form of the NTLoss
+func (l *NTLoss) String() string {
+	return fmt.Sprintf("ace %d %s: %s", l.Index, l.ACE.SDDL(), l.Reason)
+}
+
+// NTToPOSIX converts a security descriptor to the access ACL and, for
+// directories, the default ACL, like Samba does when Windows clients
+// set an ACL. Allow ACEs of the same SID are ORed, the owner and group
+// of sd become the owner entries and Everyone becomes OTHER. Inherit
+// only ACEs form the default ACL, with Creator Owner and Creator Group
+// as its owner entries. Owner entries without ACE get no permissions
+// and the mask is calculated from the group class. Deny ACEs, unknown
+// SIDs and inheritance Samba cannot represent are skipped or
+// approximated and listed in the returned losses. owner and group are
+// the IDs of sd.Owner and sd.Group. m defaults to UnixSIDMapper.
+func NTToPOSIX(sd *SecurityDescriptor, isDir bool, m SIDMapper) (access, def *ACL, owner, group uint32, losses []*NTLoss, err error) {
+	if m == nil {
+		m = UnixSIDMapper{}
+	}
+	var ok bool
+	if _, owner, ok = m.ID(sd.Owner); !ok {
+		return nil, nil, 0, 0, nil, fmt.Errorf("unmappable owner sid %q", sd.Owner)
+	}
+	if _, group, ok = m.ID(sd.Group); !ok {
+		return nil, nil, 0, 0, nil, fmt.Errorf("unmappable group sid %q", sd.Group)
+	}
+
+	losses = []*NTLoss{}
+	lost := func(i int, e *NTACE, format string, args ...any) {
+		losses = append(losses, &NTLoss{Index: i, ACE: e, Reason: fmt.Sprintf(format, args...)})
+	}
+	access, def = newNTPOSIXACL(), newNTPOSIXACL()
+	hasDefault := false
+	for i, e := range sd.DACL {
+		if e.Type != NT_ACCESS_ALLOWED_ACE_TYPE {
+			lost(i, e, "deny aces are not supported")
+			continue
+		}
+		if e.Flags&NT_NO_PROPAGATE_INHERIT_ACE != 0 {
+			lost(i, e, "no propagate inherit ignored")
+		}
+
+		inherit := e.Flags & ntInheritFlags
+		inheritOnly := e.Flags&NT_INHERIT_ONLY_ACE != 0
+		targets := []*ACL{}
+		switch {
+		case inherit == 0 && inheritOnly:
+			lost(i, e, "ace skipped: inherit only without inheritance")
+			continue
+		case inherit != 0 && !isDir:
+			if inheritOnly {
+				lost(i, e, "ace skipped: inherit only on a non-directory")
+				continue
+			}
+			lost(i, e, "inheritance flags ignored on a non-directory")
+			targets = append(targets, access)
+		case inherit != 0:
+			if inherit != ntInheritFlags {
+				lost(i, e, "inherited by both files and directories")
+			}
+			targets = append(targets, def)
+			if !inheritOnly {
+				targets = append(targets, access)
+			}
+		default:
+			targets = append(targets, access)
+		}
+
+		for _, target := range targets {
+			tag, id, ok := ntTarget(e.SID, sd, target == def, m)
+			if !ok {
+				lost(i, e, "ace skipped: unmappable sid")
+				break
+			}
+			if tag == TAG_ACL_UNDEFINED_FIELD {
+				lost(i, e, "ace skipped: creator sids only apply to inheritable aces")
+				break
+			}
+			perm := posixFromNTMask(e.Mask)
+			if existing := target.GetEntry(NewEntry(tag, id, 0)); existing != nil {
+				perm |= existing.perm
+			}
+			target.AddEntry(NewEntry(tag, id, perm))
+			if target == def {
+				hasDefault = true
+			}
+		}
+	}
+	for _, a := range []*ACL{access, def} {
+		if a.hasNamedEntries() {
+			a.CalculateMask()
+		}
+	}
+	if !hasDefault {
+		def = nil
+	}
+	return access, def, owner, group, losses, nil
+}
+
+// newNTPOSIXACL returns an ACL with owner and other entries without permissions
+func newNTPOSIXACL() *ACL {
+	a := NewACL()
+	a.AddEntry(NewEntry(TAG_ACL_USER_OBJ, ACL_UNDEFINED_ID, 0))
+	a.AddEntry(NewEntry(TAG_ACL_GROUP_OBJ, ACL_UNDEFINED_ID, 0))
+	a.AddEntry(NewEntry(TAG_ACL_OTHER, ACL_UNDEFINED_ID, 0))
+	return a
+}
+
+// ntTarget returns the POSIX entry an ACE for sid maps to. The
+// tag is TAG_ACL_UNDEFINED_FIELD for creator SIDs of access ACEs.
+func ntTarget(sid string, sd *SecurityDescriptor, isDefault bool, m SIDMapper) (Tag, uint32, bool) {
+	switch sid {
+	case SIDEveryone:
+		return TAG_ACL_OTHER, ACL_UNDEFINED_ID, true
+	case SIDCreatorOwner, SIDCreatorGroup:
+		if !isDefault {
+			return TAG_ACL_UNDEFINED_FIELD, 0, true
+		}
+		if sid == SIDCreatorOwner {
+			return TAG_ACL_USER_OBJ, ACL_UNDEFINED_ID, true
+		}
+		return TAG_ACL_GROUP_OBJ, ACL_UNDEFINED_ID, true
+	}
+	if !isDefault {
+		switch sid {
+		case sd.Owner:
+			return TAG_ACL_USER_OBJ, ACL_UNDEFINED_ID, true
+		case sd.Group:
+			return TAG_ACL_GROUP_OBJ, ACL_UNDEFINED_ID, true
+		}
+	}
+	return m.ID(sid)
+}
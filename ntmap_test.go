@@ -0,0 +1,184 @@
+package acls
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUnixSIDMapper(t *testing.T) {
+	m := UnixSIDMapper{}
+	for _, tc := range []struct {
+		tag Tag
+		id  uint32
+		sid string
+	}{
+		{TAG_ACL_USER, 1000, "S-1-22-1-1000"},
+		{TAG_ACL_GROUP, 100, "S-1-22-2-100"},
+	} {
+		sid, err := m.SID(tc.tag, tc.id)
+		if err != nil || sid != tc.sid {
+			t.Errorf("SID(%s, %d) = %s, %v, want %s", Tag2String(tc.tag), tc.id, sid, err, tc.sid)
+		}
+		tag, id, ok := m.ID(tc.sid)
+		if !ok || tag != tc.tag || id != tc.id {
+			t.Errorf("ID(%s) = %s, %d, %t", tc.sid, Tag2String(tag), id, ok)
+		}
+	}
+	for _, sid := range []string{SIDEveryone, "S-1-5-21-1-2-3-1001", "S-1-22-1-x", "S-1-22-2-4294967296"} {
+		if _, _, ok := m.ID(sid); ok {
+			t.Errorf("ID(%s) ok, want unmappable", sid)
+		}
+	}
+}
+
+func TestPOSIXToNT(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	tests := []struct {
+		name   string
+		access []*ACLEntry
+		def    []*ACLEntry
+		isDir  bool
+		want   string
+	}{
+		{
+			name: "minimal 0640",
+			access: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 6),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+			want: "O:S-1-22-1-0G:S-1-22-2-100D:(A;;0x12019f;;;S-1-22-1-0)(A;;0x120089;;;S-1-22-2-100)(A;;0x0;;;S-1-1-0)",
+		},
+		{
+			name: "mask applied",
+			access: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 1000, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_GROUP, 2000, 6),
+				NewEntry(TAG_ACL_MASK, u, 4),
+				NewEntry(TAG_ACL_OTHER, u, 0),
+			},
+			want: "O:S-1-22-1-0G:S-1-22-2-100D:(A;;0x1f01ff;;;S-1-22-1-0)(A;;0x120089;;;S-1-22-1-1000)" +
+				"(A;;0x120089;;;S-1-22-2-100)(A;;0x120089;;;S-1-22-2-2000)(A;;0x0;;;S-1-1-0)",
+		},
+		{
+			name:  "default acl inherited",
+			isDir: true,
+			access: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 1000, 6),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_MASK, u, 7),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+			def: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+				NewEntry(TAG_ACL_OTHER, u, 5),
+			},
+			want: "O:S-1-22-1-0G:S-1-22-2-100D:(A;;0x1f01ff;;;S-1-22-1-0)(A;;0x12019f;;;S-1-22-1-1000)" +
+				"(A;;0x1200a9;;;S-1-22-2-100)(A;OICI;0x1200a9;;;S-1-1-0)" +
+				"(A;OICIIO;0x1f01ff;;;S-1-3-0)(A;OICIIO;0x1200a9;;;S-1-3-1)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := NewACL()
+			for _, e := range tt.access {
+				access.AddEntry(e)
+			}
+			var def *ACL
+			if tt.def != nil {
+				def = NewACL()
+				for _, e := range tt.def {
+					def.AddEntry(e)
+				}
+			}
+			sd, err := POSIXToNT(access, def, 0, 100, tt.isDir, nil)
+			if err != nil {
+				t.Fatalf("POSIXToNT() error = %v", err)
+			}
+			if got := sd.SDDL(); got != tt.want {
+				t.Fatalf("POSIXToNT() = %s, want %s", got, tt.want)
+			}
+
+			// converting back grants the same access
+			gotAccess, gotDef, owner, group, losses, err := NTToPOSIX(sd, tt.isDir, nil)
+			if err != nil {
+				t.Fatalf("NTToPOSIX() error = %v", err)
+			}
+			if owner != 0 || group != 100 || len(losses) != 0 {
+				t.Errorf("NTToPOSIX() owner %d, group %d, losses %v", owner, group, losses)
+			}
+			if u := newCredentialUniverse(access); !u.equivalent(access, gotAccess) {
+				t.Errorf("access ACL %s not equivalent to %s", gotAccess, access)
+			}
+			if (def == nil) != (gotDef == nil) {
+				t.Fatalf("default ACL = %v, want %v", gotDef, def)
+			}
+			if def != nil && !newCredentialUniverse(def).equivalent(def, gotDef) {
+				t.Errorf("default ACL %s not equivalent to %s", gotDef, def)
+			}
+		})
+	}
+}
+
+func TestNTToPOSIX_losses(t *testing.T) {
+	sd, err := ParseSDDL("O:S-1-22-1-0G:S-1-22-2-100D:" +
+		"(A;;FA;;;S-1-22-1-0)" +
+		"(D;;FW;;;S-1-22-1-1000)" +
+		"(A;;FR;;;S-1-5-21-1-2-3-1001)" +
+		"(A;;FR;;;CO)" +
+		"(A;IO;FR;;;WD)" +
+		"(A;CI;FR;;;S-1-22-2-2000)" +
+		"(A;;GR;;;S-1-22-2-2000)" +
+		"(A;;FX;;;WD)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, def, _, _, losses, err := NTToPOSIX(sd, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLosses := []struct {
+		index  int
+		reason string
+	}{
+		{1, "deny"},
+		{2, "unmappable sid"},
+		{3, "creator sids"},
+		{4, "inherit only without inheritance"},
+		{5, "inherited by both"},
+	}
+	if len(losses) != len(wantLosses) {
+		t.Fatalf("losses = %v, want %d", losses, len(wantLosses))
+	}
+	for i, w := range wantLosses {
+		if losses[i].Index != w.index || !strings.Contains(losses[i].Reason, w.reason) {
+			t.Errorf("loss %d = %s, want index %d %q", i, losses[i], w.index, w.reason)
+		}
+	}
+	u := uint32(math.MaxUint32)
+	want := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 0),
+		NewEntry(TAG_ACL_GROUP, 2000, 4),
+		NewEntry(TAG_ACL_MASK, u, 4),
+		NewEntry(TAG_ACL_OTHER, u, 1),
+	} {
+		want.AddEntry(e)
+	}
+	if !access.Equal(want) {
+		t.Errorf("access = %s, want %s", access, want)
+	}
+	if def == nil || def.GetEntry(NewEntry(TAG_ACL_GROUP, 2000, 0)) == nil {
+		t.Errorf("default = %v, want entry for group 2000", def)
+	}
+
+	if _, _, _, _, _, err := NTToPOSIX(&SecurityDescriptor{Owner: SIDEveryone, Group: "S-1-22-2-0"}, false, nil); err == nil {
+		t.Error("NTToPOSIX() with unmappable owner succeeded")
+	}
+}
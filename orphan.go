@@ -0,0 +1,193 @@
+package acls
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// OrphanFinding is an entry referencing a user or group
+// unknown to the resolver
+type OrphanFinding struct {
+	Path  string    `json:"path"`
+	Attr  ACLAttr   `json:"attr"`
+	Entry *ACLEntry `json:"entry"`
+}
+
+// OrphanReport is the outcome of FindOrphans
+type OrphanReport struct {
+	Root string `json:"root"`
+	// Scanned is the number of paths visited
+	Scanned int64 `json:"scanned"`
+	// Findings are ordered by path, attr and entry
+	Findings []*OrphanFinding `json:"findings"`
+	Errors   []*BulkError     `json:"errors,omitempty"`
+}
+
+// JSON renders the report as indented JSON
+func (r *OrphanReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// FindOrphans scans the access and default ACLs of the tree below root
+// for named entries referencing IDs unknown to the resolver, without
+// modifying anything. opts.Attrs defaults to both attrs. Progress
+// is reported with Changed counting the paths with orphaned entries.
+func FindOrphans(ctx context.Context, root string, resolver IDResolver, opts BulkOptions) (*OrphanReport, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Attrs) == 0 {
+		opts.Attrs = []ACLAttr{PosixACLAccess, PosixACLDefault}
+	}
+	resolver = newCachedResolver(resolver)
+	b := newBulkRun(opts)
+	mu := sync.Mutex{}
+	report := &OrphanReport{Root: root, Findings: []*OrphanFinding{}}
+
+	err = walkTree(ctx, root, opts.Workers, opts.RateLimit, func(fsPath string, d fs.DirEntry, err error) {
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		findings := []*OrphanFinding{}
+		for _, attr := range b.attrsFor(d) {
+			a, err := loadForCompare(fsPath, attr, opts.Logger)
+			if err != nil {
+				b.record(fsPath, false, &BulkError{Path: fsPath, Attr: attr, Err: err})
+				return
+			}
+			orphans, err := orphanedEntries(resolver, a)
+			if err != nil {
+				b.record(fsPath, false, &BulkError{Path: fsPath, Attr: attr, Err: err})
+				return
+			}
+			for _, e := range orphans {
+				findings = append(findings, &OrphanFinding{Path: fsPath, Attr: attr, Entry: e})
+			}
+		}
+		mu.Lock()
+		report.Findings = append(report.Findings, findings...)
+		mu.Unlock()
+		b.record(fsPath, len(findings) > 0, nil)
+	})
+
+	result := b.finish()
+	report.Scanned = result.Scanned
+	report.Errors = result.Errors
+	sort.Slice(report.Findings, func(i, j int) bool {
+		fi, fj := report.Findings[i], report.Findings[j]
+		if fi.Path != fj.Path {
+			return fi.Path < fj.Path
+		}
+		if fi.Attr != fj.Attr {
+			return fi.Attr < fj.Attr
+		}
+		return entryLess(fi.Entry, fj.Entry)
+	})
+	return report, err
+}
+
+// CleanOrphans rewrites the access and default ACLs of the tree below
+// root with OrphanTransform. With dryRun set nothing is written and the
+// report lists the planned changes. opts.Attrs is ignored and Progress
+// is only reported while applying.
+func CleanOrphans(ctx context.Context, root string, resolver IDResolver, m IDMapper, dryRun bool, opts BulkOptions) (*ReconcileReport, error) {
+	return reconcile(ctx, root, OrphanTransform(resolver, m), dryRun, opts)
+}
+
+// OrphanTransform returns a TransformFunc handling entries referencing
+// IDs unknown to the resolver: orphans mapped by m are remapped, all
+// others are removed. m may be nil to remove all orphans. The mask is
+// left untouched, as removing entries never requires changing it.
+func OrphanTransform(resolver IDResolver, m IDMapper) TransformFunc {
+	resolver = newCachedResolver(resolver)
+	return func(_ string, _ fs.DirEntry, _ ACLAttr, a *ACL) error {
+		orphans, err := orphanedEntries(resolver, a)
+		if err != nil {
+			return err
+		}
+		users, groups := map[uint32]uint32{}, map[uint32]uint32{}
+		for _, e := range orphans {
+			if m != nil {
+				if id, ok := m(e.tag, e.id); ok {
+					if e.tag == TAG_ACL_GROUP {
+						groups[e.id] = id
+					} else {
+						users[e.id] = id
+					}
+					continue
+				}
+			}
+			a.DeleteEntry(e)
+		}
+		a.Remap(IDTable(users, groups))
+		return nil
+	}
+}
+
+// orphanedEntries returns the entries of a referencing
+// principals unknown to the resolver
+func orphanedEntries(resolver IDResolver, a *ACL) ([]*ACLEntry, error) {
+	result := []*ACLEntry{}
+	for _, e := range a.entries {
+		ok, err := entryPrincipalExists(resolver, e)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// cachedResolver caches the answers of an IDResolver,
+// as trees reference the same IDs over and over
+type cachedResolver struct {
+	resolver IDResolver
+	mu       sync.Mutex
+	users    map[uint32]bool
+	groups   map[uint32]bool
+}
+
+// newCachedResolver wraps r in a cachedResolver
+func newCachedResolver(r IDResolver) *cachedResolver {
+	if c, ok := r.(*cachedResolver); ok {
+		return c
+	}
+	return &cachedResolver{resolver: r, users: map[uint32]bool{}, groups: map[uint32]bool{}}
+}
+
+// UserExists reports whether a user with the given uid exists
+func (c *cachedResolver) UserExists(uid uint32) (bool, error) {
+	return c.lookup(c.users, uid, c.resolver.UserExists)
+}
+
+// GroupExists reports whether a group with the given gid exists
+func (c *cachedResolver) GroupExists(gid uint32) (bool, error) {
+	return c.lookup(c.groups, gid, c.resolver.GroupExists)
+}
+
+// lookup returns the cached answer for id or asks exists,
+// errors are not cached
+func (c *cachedResolver) lookup(cache map[uint32]bool, id uint32, exists func(uint32) (bool, error)) (bool, error) {
+	c.mu.Lock()
+	ok, cached := cache[id]
+	c.mu.Unlock()
+	if cached {
+		return ok, nil
+	}
+	ok, err := exists(id)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	cache[id] = ok
+	c.mu.Unlock()
+	return ok, nil
+}
@@ -0,0 +1,93 @@
+package acls
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestOrphanTransform(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	resolver := mapResolver{users: map[uint32]bool{1000: true}, groups: map[uint32]bool{2000: true}}
+	tests := []struct {
+		name    string
+		mapper  IDMapper
+		entries []*ACLEntry
+		want    []*ACLEntry
+	}{
+		{
+			name: "orphans removed",
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 1000, 6),
+				NewEntry(TAG_ACL_USER, 1001, 6),
+				NewEntry(TAG_ACL_GROUP, 2001, 4),
+				NewEntry(TAG_ACL_MASK, u, 6),
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, u, 7),
+				NewEntry(TAG_ACL_USER, 1000, 6),
+				NewEntry(TAG_ACL_MASK, u, 6),
+			},
+		},
+		{
+			name:   "mapped orphans remapped",
+			mapper: IDTable(map[uint32]uint32{1001: 1000}, nil),
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER, 1000, 4),
+				NewEntry(TAG_ACL_USER, 1001, 2),
+				NewEntry(TAG_ACL_GROUP, 1001, 4),
+			},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER, 1000, 6),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &ACL{version: 2, entries: tt.entries}
+			if err := OrphanTransform(resolver, tt.mapper)("/x", nil, PosixACLAccess, a); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			want := &ACL{version: 2, entries: tt.want}
+			if !a.Equal(want) {
+				t.Errorf("got %v, want %v", a, want)
+			}
+		})
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	root := createTestTree(t, "a/file1", "b/")
+	file1 := filepath.Join(root, "a", "file1")
+	addOnDisk(t, file1, NewEntry(TAG_ACL_USER, 4711, PermRead))
+	addOnDisk(t, file1, NewEntry(TAG_ACL_USER, 4712, PermRead))
+	resolver := mapResolver{users: map[uint32]bool{4712: true}}
+
+	report, err := FindOrphans(context.Background(), root, resolver, BulkOptions{})
+	if err != nil {
+		t.Fatalf("FindOrphans() unexpected error %v", err)
+	}
+	if report.Scanned != 4 || len(report.Errors) != 0 {
+		t.Errorf("unexpected scanned %d, errors %v", report.Scanned, report.Errors)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].Path != file1 || report.Findings[0].Entry.ID() != 4711 {
+		t.Fatalf("expected user 4711 on %s, got %v", file1, report.Findings)
+	}
+
+	report2, err := CleanOrphans(context.Background(), root, resolver, nil, false, BulkOptions{})
+	if err != nil {
+		t.Fatalf("CleanOrphans() unexpected error %v", err)
+	}
+	if report2.Changed != 1 || report2.Failed != 0 {
+		t.Errorf("unexpected counters %+v, errors %v", report2.BulkProgress, report2.Errors)
+	}
+	a := NewACL()
+	if err := a.Load(file1, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) != nil || a.GetEntry(NewEntry(TAG_ACL_USER, 4712, 0)) == nil {
+		t.Errorf("expected only orphan 4711 removed, got %s", a.String())
+	}
+}
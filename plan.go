@@ -0,0 +1,189 @@
+package acls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PlannedChange is the change of a single ACL attribute of a path
+type PlannedChange struct {
+	Path string  `json:"path"`
+	Attr ACLAttr `json:"attr"`
+	// Existed reports whether the attribute existed when the plan
+	// was made, or Before was bootstrapped from the file mode
+	Existed bool `json:"existed"`
+	// Before is the ACL on disk when the plan was made
+	Before *ACL `json:"before"`
+	// After is the ACL that will be applied
+	After *ACL `json:"after"`
+	// Diff holds the entry changes from Before to After
+	Diff []EntryDiff `json:"diff"`
+}
+
+// Plan holds the changes a bulk operation would apply, without
+// having modified anything. It can be reviewed, stored as JSON
+// and executed later.
+type Plan struct {
+	Root string `json:"root"`
+	// Scanned is the number of paths visited while planning
+	Scanned int64 `json:"scanned"`
+	// Changes holds the planned changes ordered by path and attr
+	Changes []*PlannedChange `json:"changes"`
+	// Errors holds the paths that could not be planned
+	Errors []*BulkError `json:"errors,omitempty"`
+}
+
+// BuildPlan walks the tree below root like BulkApply, but instead of
+// applying the ACLs computed by f it records the changes in a Plan.
+// Progress is reported with Changed counting the paths that would change.
+func BuildPlan(ctx context.Context, root string, f TransformFunc, opts BulkOptions) (*Plan, error) {
+	b := newBulkRun(opts)
+	mu := sync.Mutex{}
+	plan := &Plan{Root: root, Changes: []*PlannedChange{}}
+
+	err := walkTree(ctx, root, opts.Workers, opts.RateLimit, func(fsPath string, d fs.DirEntry, err error) {
+		if err != nil {
+			b.record(fsPath, false, &BulkError{Path: fsPath, Err: err})
+			return
+		}
+		changes := []*PlannedChange{}
+		for _, attr := range b.attrsFor(d) {
+			current, desired, exists, err := b.compute(fsPath, d, attr, f)
+			if err != nil {
+				b.record(fsPath, false, &BulkError{Path: fsPath, Attr: attr, Err: err})
+				return
+			}
+			diff := current.Diff(desired)
+			if len(diff) == 0 {
+				continue
+			}
+			changes = append(changes, &PlannedChange{
+				Path:    fsPath,
+				Attr:    attr,
+				Existed: exists,
+				Before:  current,
+				After:   desired,
+				Diff:    diff,
+			})
+		}
+		mu.Lock()
+		plan.Changes = append(plan.Changes, changes...)
+		mu.Unlock()
+		b.record(fsPath, len(changes) > 0, nil)
+	})
+
+	result := b.finish()
+	plan.Scanned = result.Scanned
+	plan.Errors = result.Errors
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		if plan.Changes[i].Path != plan.Changes[j].Path {
+			return plan.Changes[i].Path < plan.Changes[j].Path
+		}
+		return plan.Changes[i].Attr < plan.Changes[j].Attr
+	})
+	return plan, err
+}
+
+// String renders the plan as human readable text
+func (p *Plan) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Plan for %s: %d change(s), %d path(s) scanned, %d error(s)\n", p.Root, len(p.Changes), p.Scanned, len(p.Errors))
+	for _, c := range p.Changes {
+		fmt.Fprintf(sb, "%s (%s)\n", c.Path, c.Attr)
+		for _, d := range c.Diff {
+			fmt.Fprintf(sb, "  %s\n", d.String())
+		}
+	}
+	for _, e := range p.Errors {
+		fmt.Fprintf(sb, "error: %s\n", e.Error())
+	}
+	return sb.String()
+}
+
+// JSON renders the plan as indented JSON
+func (p *Plan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// LoadPlan decodes a plan previously rendered via JSON
+func LoadPlan(b []byte) (*Plan, error) {
+	p := &Plan{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, err
+	}
+	for _, c := range p.Changes {
+		if c.Before == nil || c.After == nil {
+			return nil, fmt.Errorf("planned change of %q %s lacks before or after state", c.Path, c.Attr)
+		}
+	}
+	return p, nil
+}
+
+// Execute applies the planned changes. Every change is only applied if
+// the ACL on disk still equals the state recorded in the plan, otherwise
+// the path fails with ErrConflict. Workers, RateLimit, Progress, Logger
+// and Journal of opts are honored, Attrs is ignored. Like BulkApply,
+// per path failures are collected in the result, the counters of which
+// refer to the planned changes rather than paths. Only changes passing
+// the validation are journaled, with the state found on disk.
+func (p *Plan) Execute(ctx context.Context, opts BulkOptions) (*BulkResult, error) {
+	b := newBulkRun(opts)
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	tick, stopTick := rateTicker(opts.RateLimit)
+	defer stopTick()
+
+	changes := make(chan *PlannedChange)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range changes {
+				after := c.After.Clone()
+				after.SetLogger(opts.Logger)
+				// journal the state on disk only once it is validated, a
+				// conflicting path is left untouched and must not be rolled back
+				err := after.compareAndApply(c.Path, c.Attr, c.Before, func(current *ACL, exists bool) error {
+					return b.journal(c.Path, c.Attr, current, exists)
+				})
+				if err != nil {
+					b.record(c.Path, false, &BulkError{Path: c.Path, Attr: c.Attr, Err: err})
+					continue
+				}
+				b.record(c.Path, true, nil)
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for _, c := range p.Changes {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				err = ctx.Err()
+				break feed
+			}
+		}
+		select {
+		case changes <- c:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(changes)
+	wg.Wait()
+
+	return b.finish(), err
+}
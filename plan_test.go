@@ -0,0 +1,166 @@
+package acls
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildPlan(t *testing.T) {
+	root := createTestTree(t, "a/file1", "file2")
+
+	plan, err := BuildPlan(context.Background(), root, addUserTransform(4711), BulkOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() unexpected error %v", err)
+	}
+	if plan.Scanned != 4 || len(plan.Changes) != 4 {
+		t.Fatalf("expected 4 scanned paths and 4 changes, got %d and %d", plan.Scanned, len(plan.Changes))
+	}
+
+	// nothing must have been written
+	a := NewACL()
+	if err := a.Load(filepath.Join(root, "file2"), PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) != nil {
+		t.Errorf("BuildPlan() modified the filesystem")
+	}
+
+	text := plan.String()
+	if !strings.Contains(text, filepath.Join(root, "a", "file1")+" (system.posix_acl_access)\n  + USER:4711:r--\n") {
+		t.Errorf("unexpected text rendering:\n%s", text)
+	}
+
+	// round trip via JSON and execute
+	b, err := plan.JSON()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	loaded, err := LoadPlan(b)
+	if err != nil {
+		t.Fatalf("LoadPlan() unexpected error %v", err)
+	}
+	if loaded.String() != text {
+		t.Errorf("JSON round trip changed the plan:\n%s\nwant:\n%s", loaded.String(), text)
+	}
+
+	result, err := loaded.Execute(context.Background(), BulkOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("Execute() unexpected error %v", err)
+	}
+	if result.Changed != 4 || result.Failed != 0 {
+		t.Errorf("unexpected counters %+v, errors %v", result.BulkProgress, result.Errors)
+	}
+	if err := a.Load(filepath.Join(root, "file2"), PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) == nil {
+		t.Errorf("Execute() did not apply the plan, got %s", a.String())
+	}
+}
+
+func TestBuildPlan_ownerQualifiers(t *testing.T) {
+	root := createTestTree(t, "file")
+
+	// qualifiers of the owner entries are ignored by the kernel,
+	// setting them must not show up as change without diff
+	withOwnerIDs := func(fsPath string, d fs.DirEntry, attr ACLAttr, a *ACL) error {
+		for i, e := range a.entries {
+			if e.tag == TAG_ACL_USER_OBJ || e.tag == TAG_ACL_GROUP_OBJ {
+				a.entries[i] = NewEntry(e.tag, 1000, e.perm)
+			}
+		}
+		return nil
+	}
+	plan, err := BuildPlan(context.Background(), root, withOwnerIDs, BulkOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() unexpected error %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("expected no changes, got %s", plan.String())
+	}
+}
+
+func TestPlan_ExecuteDrift(t *testing.T) {
+	root := createTestTree(t, "file1", "file2")
+
+	plan, err := BuildPlan(context.Background(), root, addUserTransform(4711), BulkOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() unexpected error %v", err)
+	}
+
+	// drift after planning
+	drifted := filepath.Join(root, "file1")
+	addOnDisk(t, drifted, NewEntry(TAG_ACL_GROUP, 1234, PermRead))
+
+	result, err := plan.Execute(context.Background(), BulkOptions{})
+	if err != nil {
+		t.Fatalf("Execute() unexpected error %v", err)
+	}
+	if result.Changed != 2 || result.Failed != 1 {
+		t.Errorf("unexpected counters %+v", result.BulkProgress)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Path != drifted || !errors.Is(result.Errors[0], ErrConflict) {
+		t.Errorf("expected conflict for %q, got %v", drifted, result.Errors)
+	}
+}
+
+func TestPlan_ExecuteConflictRollback(t *testing.T) {
+	root := createTestTree(t, "file1", "file2")
+	plan, err := BuildPlan(context.Background(), root, addUserTransform(4711), BulkOptions{})
+	if err != nil {
+		t.Fatalf("BuildPlan() unexpected error %v", err)
+	}
+
+	// a concurrent writer modifies file1 after planning
+	drifted := filepath.Join(root, "file1")
+	addOnDisk(t, drifted, NewEntry(TAG_ACL_GROUP, 1234, PermRead))
+
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	j, err := OpenJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := plan.Execute(context.Background(), BulkOptions{Journal: j})
+	j.Close()
+	if err != nil || result.Failed != 1 {
+		t.Fatalf("Execute() = %+v, %v, want one conflict", result, err)
+	}
+	records, err := ReadJournal(journalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if r.Path == drifted {
+			t.Errorf("journal holds a record for the conflicting path %q", drifted)
+		}
+	}
+
+	if _, err := Rollback(context.Background(), journalPath, BulkOptions{}); err != nil {
+		t.Fatalf("Rollback() unexpected error %v", err)
+	}
+	a := NewACL()
+	if err := a.Load(drifted, PosixACLAccess); err != nil {
+		t.Fatal(err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_GROUP, 1234, 0)) == nil {
+		t.Errorf("Rollback() overwrote the concurrent change, got %s", a)
+	}
+	if err := a.Load(filepath.Join(root, "file2"), PosixACLAccess); err != nil {
+		t.Fatal(err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) != nil {
+		t.Errorf("Rollback() did not restore file2, got %s", a)
+	}
+}
+
+func TestLoadPlan_Invalid(t *testing.T) {
+	for _, s := range []string{`{`, `{"changes":[{"path":"/tmp/x","attr":"system.posix_acl_access"}]}`} {
+		if _, err := LoadPlan([]byte(s)); err == nil {
+			t.Errorf("expected error loading %s", s)
+		}
+	}
+}
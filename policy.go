@@ -0,0 +1,278 @@
+package acls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy describes the desired ACLs of a filesystem tree.
+// It is stored as JSON:
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "path": "/srv/projects/*/data",
+//	      "access": ["g:analysts:r-X"],
+//	      "default": ["g:analysts:r-X"],
+//	      "purge": true
+//	    }
+//	  ]
+//	}
+type Policy struct {
+	Rules []*PolicyRule `json:"rules"`
+}
+
+// PolicyRule defines the required entries of all paths matching Path.
+// Path is an absolute glob in the syntax of filepath.Match per path
+// element, additionally "**" matches any number of path elements.
+// If multiple rules match a path, they are applied in order.
+type PolicyRule struct {
+	Path string `json:"path"`
+	// Access holds the required entries of the access ACL
+	Access []string `json:"access,omitempty"`
+	// Default holds the required entries of the default ACL,
+	// which only applies to directories
+	Default []string `json:"default,omitempty"`
+	// Purge removes named user and group entries not required
+	// by any matching rule, instead of allowing extra entries
+	Purge bool `json:"purge,omitempty"`
+
+	access      []*EntrySpec
+	defaultACL  []*EntrySpec
+	pathPattern []string
+}
+
+// LoadPolicy decodes and validates a policy
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("failed decoding policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadPolicyFile loads and validates the policy stored in the given file
+func LoadPolicyFile(policyPath string) (*Policy, error) {
+	f, err := os.Open(policyPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadPolicy(f)
+}
+
+// Validate checks the rules of the policy and prepares them for use.
+// It must be called if the Policy was not created via LoadPolicy.
+func (p *Policy) Validate() error {
+	for i, r := range p.Rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("policy rule %d (%q): %w", i, r.Path, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the rule and parses its path and entries
+func (r *PolicyRule) validate() error {
+	if !filepath.IsAbs(r.Path) {
+		return fmt.Errorf("path must be absolute")
+	}
+	r.pathPattern = splitPath(r.Path)
+	for _, elem := range r.pathPattern {
+		if _, err := filepath.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	if len(r.Access) == 0 && len(r.Default) == 0 && !r.Purge {
+		return fmt.Errorf("rule neither requires entries nor purges")
+	}
+
+	var err error
+	if r.access, err = parseEntrySpecs(r.Access); err != nil {
+		return fmt.Errorf("access: %w", err)
+	}
+	if r.defaultACL, err = parseEntrySpecs(r.Default); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	return nil
+}
+
+// parseEntrySpecs parses the given entries and
+// rejects duplicates of the same Tag and ID
+func parseEntrySpecs(specs []string) ([]*EntrySpec, error) {
+	result := make([]*EntrySpec, 0, len(specs))
+	for _, s := range specs {
+		spec, err := ParseEntrySpec(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range result {
+			if other.Tag == spec.Tag && other.ID == spec.ID {
+				return nil, fmt.Errorf("duplicate entry %q", s)
+			}
+		}
+		result = append(result, spec)
+	}
+	return result, nil
+}
+
+// splitPath splits a cleaned absolute path into its elements
+func splitPath(p string) []string {
+	p = strings.Trim(filepath.Clean(p), "/")
+	if p == "" {
+		return []string{}
+	}
+	return strings.Split(p, "/")
+}
+
+// matches reports whether the rule applies to the given absolute path
+func (r *PolicyRule) matches(fsPath string) bool {
+	return matchElems(r.pathPattern, splitPath(fsPath))
+}
+
+// matchElems matches path elements against pattern elements,
+// where "**" matches any number of elements
+func matchElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// Transform returns a TransformFunc bringing the ACLs of the
+// paths matching a rule into the state required by the policy.
+// The paths passed to the TransformFunc must be absolute.
+func (p *Policy) Transform() TransformFunc {
+	return func(fsPath string, d fs.DirEntry, attr ACLAttr, a *ACL) error {
+		matched := false
+		purge := false
+		required := map[EntrySpec]bool{}
+		current := a.Clone()
+
+		for _, r := range p.Rules {
+			if !r.matches(fsPath) {
+				continue
+			}
+			matched = true
+			purge = purge || r.Purge
+			specs := r.access
+			if attr == PosixACLDefault {
+				specs = r.defaultACL
+			}
+			for _, spec := range specs {
+				required[EntrySpec{Tag: spec.Tag, ID: spec.ID}] = true
+				a.AddEntry(spec.Entry(d.IsDir(), current))
+			}
+		}
+		if !matched {
+			return nil
+		}
+
+		if purge {
+			for _, e := range a.GetEntries() {
+				if (e.tag == TAG_ACL_USER || e.tag == TAG_ACL_GROUP) && !required[EntrySpec{Tag: e.tag, ID: e.id}] {
+					a.DeleteEntry(e)
+				}
+			}
+		}
+		// keep an explicitly required mask, otherwise recalculate it
+		if a.hasNamedEntries() && !required[EntrySpec{Tag: TAG_ACL_MASK, ID: ACL_UNDEFINED_ID}] {
+			a.CalculateMask()
+		}
+		return nil
+	}
+}
+
+// pathAttr identifies an ACL attribute of a path
+type pathAttr struct {
+	path string
+	attr ACLAttr
+}
+
+// ReconcileReport is the outcome of Reconcile and RemapTree
+type ReconcileReport struct {
+	// BulkProgress holds the number of scanned paths,
+	// applied changes and failures
+	BulkProgress
+	// Changes holds the applied changes, or the planned ones on a
+	// dry run, including the before and after state and diff of
+	// every path
+	Changes []*PlannedChange
+	// Errors holds the errors of all failed paths
+	Errors []*BulkError
+}
+
+// Reconcile walks the tree below root, compares the access and default
+// ACLs with the policy and applies the required changes. Only paths
+// deviating from the policy are written. opts.Attrs is ignored and
+// Progress is only reported while applying.
+func (p *Policy) Reconcile(ctx context.Context, root string, opts BulkOptions) (*ReconcileReport, error) {
+	return reconcile(ctx, root, p.Transform(), false, opts)
+}
+
+// reconcile plans the changes f makes to the access and default ACLs
+// below root and applies them unless dryRun is set
+func reconcile(ctx context.Context, root string, f TransformFunc, dryRun bool, opts BulkOptions) (*ReconcileReport, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	opts.Attrs = []ACLAttr{PosixACLAccess, PosixACLDefault}
+
+	planOpts := opts
+	planOpts.Progress = nil
+	plan, err := BuildPlan(ctx, root, f, planOpts)
+	report := &ReconcileReport{
+		BulkProgress: BulkProgress{Scanned: plan.Scanned, Failed: int64(len(plan.Errors))},
+		Changes:      []*PlannedChange{},
+		Errors:       append([]*BulkError{}, plan.Errors...),
+	}
+	if err != nil {
+		return report, err
+	}
+	if dryRun {
+		report.Changes = append(report.Changes, plan.Changes...)
+		return report, nil
+	}
+
+	result, err := plan.Execute(ctx, opts)
+	report.Changed = result.Changed
+	report.Failed += result.Failed
+	report.Errors = append(report.Errors, result.Errors...)
+
+	failed := map[pathAttr]bool{}
+	for _, e := range result.Errors {
+		failed[pathAttr{path: e.Path, attr: e.Attr}] = true
+	}
+	for _, c := range plan.Changes {
+		if !failed[pathAttr{path: c.Path, attr: c.Attr}] {
+			report.Changes = append(report.Changes, c)
+		}
+	}
+	return report, err
+}
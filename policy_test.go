@@ -0,0 +1,158 @@
+package acls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			policy: `{"rules":[{"path":"/srv/projects/*/data","access":["g:5558:r-X"],"default":["g:5558:r-X"],"purge":true}]}`,
+		},
+		{
+			name:    "relative path",
+			policy:  `{"rules":[{"path":"srv/*","access":["g:5558:r-X"]}]}`,
+			wantErr: "must be absolute",
+		},
+		{
+			name:    "bad glob",
+			policy:  `{"rules":[{"path":"/srv/[","access":["g:5558:r-X"]}]}`,
+			wantErr: "syntax error in pattern",
+		},
+		{
+			name:    "invalid entry",
+			policy:  `{"rules":[{"path":"/srv","access":["g:5558"]}]}`,
+			wantErr: "invalid entry",
+		},
+		{
+			name:    "duplicate entry",
+			policy:  `{"rules":[{"path":"/srv","default":["g:5558:r--","g:5558:rwx"]}]}`,
+			wantErr: "duplicate entry",
+		},
+		{
+			name:    "empty rule",
+			policy:  `{"rules":[{"path":"/srv"}]}`,
+			wantErr: "neither requires entries nor purges",
+		},
+		{
+			name:    "unknown field",
+			policy:  `{"rules":[{"path":"/srv","acces":["g:5558:r--"]}]}`,
+			wantErr: "unknown field",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPolicy(strings.NewReader(tt.policy))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("LoadPolicy() unexpected error %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("LoadPolicy() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyRule_matches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "/srv/projects/*/data", path: "/srv/projects/a/data", want: true},
+		{pattern: "/srv/projects/*/data", path: "/srv/projects/a/data/file", want: false},
+		{pattern: "/srv/projects/*/data", path: "/srv/projects/a/b/data", want: false},
+		{pattern: "/srv/projects/*/data/**", path: "/srv/projects/a/data", want: true},
+		{pattern: "/srv/projects/*/data/**", path: "/srv/projects/a/data/x/y", want: true},
+		{pattern: "/srv/**/data", path: "/srv/a/b/c/data", want: true},
+		{pattern: "/srv/**/data", path: "/srv/a/b/c/other", want: false},
+		{pattern: "/", path: "/", want: true},
+	}
+	for _, tt := range tests {
+		r := &PolicyRule{Path: tt.pattern, Purge: true}
+		if err := r.validate(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got := r.matches(tt.path); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Reconcile(t *testing.T) {
+	root := createTestTree(t, "projects/a/data/file1", "projects/a/other/file2", "projects/b/data/")
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	policy := `{"rules":[
+		{"path":"` + root + `/projects/*/data/**","access":["g:5558:r-X"],"default":["g:5558:r-X"],"purge":true}
+	]}`
+	if err := os.WriteFile(policyPath, []byte(policy), 0o600); err != nil {
+		t.Fatalf("failed writing policy %v", err)
+	}
+	p, err := LoadPolicyFile(policyPath)
+	if err != nil {
+		t.Fatalf("LoadPolicyFile() unexpected error %v", err)
+	}
+
+	// an extra entry that must be purged
+	file1 := filepath.Join(root, "projects", "a", "data", "file1")
+	addOnDisk(t, file1, NewEntry(TAG_ACL_USER, 4711, PermAll))
+
+	report, err := p.Reconcile(context.Background(), root, BulkOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile() unexpected error %v", err)
+	}
+	if report.Scanned != 9 || report.Failed != 0 {
+		t.Errorf("unexpected counters %+v, errors %v", report.BulkProgress, report.Errors)
+	}
+	// 3 access ACLs plus 2 default ACLs of the data directories
+	if report.Changed != 5 || len(report.Changes) != 5 {
+		t.Errorf("expected 5 changes, got %d: %v", report.Changed, report.Changes)
+	}
+
+	a := NewACL()
+	if err := a.Load(file1, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) != nil {
+		t.Errorf("extra entry not purged: %s", a.String())
+	}
+	if e := a.GetEntry(NewEntry(TAG_ACL_GROUP, 5558, 0)); e == nil || e.Perm() != PermRead {
+		t.Errorf("expected group 5558 with r-- on file, got %s", a.String())
+	}
+	if e := a.GetEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0)); e == nil || !e.HasPerm(PermRead) {
+		t.Errorf("expected mask covering group 5558, got %s", a.String())
+	}
+
+	if err := a.Load(filepath.Join(root, "projects", "b", "data"), PosixACLDefault); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if e := a.GetEntry(NewEntry(TAG_ACL_GROUP, 5558, 0)); e == nil || e.Perm() != PermRead|PermExecute {
+		t.Errorf("expected default group 5558 with r-x on directory, got %s", a.String())
+	}
+
+	// unmatched paths are untouched
+	if err := a.Load(filepath.Join(root, "projects", "a", "other", "file2"), PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if a.hasNamedEntries() {
+		t.Errorf("unmatched path modified: %s", a.String())
+	}
+
+	// the second run is a no-op
+	report, err = p.Reconcile(context.Background(), root, BulkOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile() unexpected error %v", err)
+	}
+	if report.Changed != 0 {
+		t.Errorf("expected no changes in second run, got %v", report.Changes)
+	}
+}
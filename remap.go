@@ -0,0 +1,69 @@
+package acls
+
+import (
+	"context"
+	"io/fs"
+	"slices"
+)
+
+// IDMapper translates the qualifier of a named USER or GROUP entry,
+// ok is false if id is not mapped and stays unchanged
+type IDMapper func(tag Tag, id uint32) (newID uint32, ok bool)
+
+// IDTable returns an IDMapper backed by translation tables for
+// uids and gids, IDs missing in the tables are not mapped
+func IDTable(users, groups map[uint32]uint32) IDMapper {
+	return func(tag Tag, id uint32) (uint32, bool) {
+		table := users
+		if tag == TAG_ACL_GROUP {
+			table = groups
+		}
+		newID, ok := table[id]
+		return newID, ok
+	}
+}
+
+// Remap rewrites the qualifiers of the named USER and GROUP entries
+// according to m and returns the number of rewritten entries. All
+// entries are mapped at once, so IDs can be swapped. Entries ending
+// up with the same qualifier are merged by ORing their permissions,
+// which leaves the union of the group class and thus the mask intact.
+func (a *ACL) Remap(m IDMapper) int {
+	remapped := 0
+	entries := make([]*ACLEntry, 0, len(a.entries))
+	for _, e := range a.entries {
+		id := e.id
+		if e.tag == TAG_ACL_USER || e.tag == TAG_ACL_GROUP {
+			if newID, ok := m(e.tag, e.id); ok && newID != e.id {
+				id = newID
+				remapped++
+			}
+		}
+		mapped := NewEntry(e.tag, id, e.perm)
+		if existing := findEntry(entries, mapped); existing != nil {
+			a.log().Debug("remapped acl entries merged", "tag", Tag2String(e.tag), "id", id, "perm", PermUintToString(existing.perm|e.perm))
+			entries[slices.Index(entries, existing)] = existing.WithPerm(e.perm)
+			continue
+		}
+		entries = append(entries, mapped)
+	}
+	a.entries = entries
+	return remapped
+}
+
+// Transform returns a TransformFunc remapping the ACLs it is applied to
+func (m IDMapper) Transform() TransformFunc {
+	return func(_ string, _ fs.DirEntry, _ ACLAttr, a *ACL) error {
+		a.Remap(m)
+		return nil
+	}
+}
+
+// RemapTree remaps the access and default ACLs of the tree below root
+// according to m. Only paths referencing mapped IDs are written. With
+// dryRun set nothing is written and the report lists the planned
+// changes. opts.Attrs is ignored and Progress is only reported while
+// applying.
+func RemapTree(ctx context.Context, root string, m IDMapper, dryRun bool, opts BulkOptions) (*ReconcileReport, error) {
+	return reconcile(ctx, root, m.Transform(), dryRun, opts)
+}
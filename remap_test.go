@@ -0,0 +1,109 @@
+package acls
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestACL_Remap(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	tests := []struct {
+		name     string
+		entries  []*ACLEntry
+		users    map[uint32]uint32
+		groups   map[uint32]uint32
+		want     []*ACLEntry
+		remapped int
+	}{
+		{
+			name: "users and groups mapped separately",
+			entries: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+				NewEntry(TAG_ACL_USER, 1000, 6),
+				NewEntry(TAG_ACL_GROUP, 1000, 4),
+				NewEntry(TAG_ACL_MASK, u, 6),
+			},
+			users:  map[uint32]uint32{1000: 5000},
+			groups: map[uint32]uint32{1000: 6000},
+			want: []*ACLEntry{
+				NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+				NewEntry(TAG_ACL_USER, 5000, 6),
+				NewEntry(TAG_ACL_GROUP, 6000, 4),
+				NewEntry(TAG_ACL_MASK, u, 6),
+			},
+			remapped: 2,
+		},
+		{
+			name:     "swap",
+			entries:  []*ACLEntry{NewEntry(TAG_ACL_USER, 1000, 4), NewEntry(TAG_ACL_USER, 2000, 2)},
+			users:    map[uint32]uint32{1000: 2000, 2000: 1000},
+			want:     []*ACLEntry{NewEntry(TAG_ACL_USER, 2000, 4), NewEntry(TAG_ACL_USER, 1000, 2)},
+			remapped: 2,
+		},
+		{
+			name:     "collision merged",
+			entries:  []*ACLEntry{NewEntry(TAG_ACL_GROUP, 1000, 4), NewEntry(TAG_ACL_GROUP, 2000, 1), NewEntry(TAG_ACL_GROUP, 3000, 2)},
+			groups:   map[uint32]uint32{1000: 3000, 2000: 3000},
+			want:     []*ACLEntry{NewEntry(TAG_ACL_GROUP, 3000, 7)},
+			remapped: 2,
+		},
+		{
+			name:     "unmapped untouched",
+			entries:  []*ACLEntry{NewEntry(TAG_ACL_USER, 1000, 4)},
+			users:    map[uint32]uint32{1000: 1000, 2000: 3000},
+			want:     []*ACLEntry{NewEntry(TAG_ACL_USER, 1000, 4)},
+			remapped: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &ACL{version: 2, entries: tt.entries}
+			remapped := a.Remap(IDTable(tt.users, tt.groups))
+			want := &ACL{version: 2, entries: tt.want}
+			if !a.Equal(want) {
+				t.Errorf("Remap() = %v, want %v", a, want)
+			}
+			if remapped != tt.remapped {
+				t.Errorf("Remap() = %d, want %d", remapped, tt.remapped)
+			}
+		})
+	}
+}
+
+func TestRemapTree(t *testing.T) {
+	root := createTestTree(t, "a/file1", "b/file2")
+	file1 := filepath.Join(root, "a", "file1")
+	addOnDisk(t, file1, NewEntry(TAG_ACL_USER, 4711, PermRead))
+	m := IDTable(map[uint32]uint32{4711: 4712}, nil)
+
+	report, err := RemapTree(context.Background(), root, m, true, BulkOptions{})
+	if err != nil {
+		t.Fatalf("RemapTree() unexpected error %v", err)
+	}
+	if report.Changed != 0 || len(report.Changes) != 1 || report.Changes[0].Path != file1 {
+		t.Errorf("expected one planned change of %s, got %d applied, %v", file1, report.Changed, report.Changes)
+	}
+	a := NewACL()
+	if err := a.Load(file1, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) == nil {
+		t.Errorf("dry run modified %s: %s", file1, a.String())
+	}
+
+	report, err = RemapTree(context.Background(), root, m, false, BulkOptions{})
+	if err != nil {
+		t.Fatalf("RemapTree() unexpected error %v", err)
+	}
+	if report.Changed != 1 || report.Failed != 0 {
+		t.Errorf("unexpected counters %+v, errors %v", report.BulkProgress, report.Errors)
+	}
+	if err := a.Load(file1, PosixACLAccess); err != nil {
+		t.Fatalf("failed loading ACL %v", err)
+	}
+	if a.GetEntry(NewEntry(TAG_ACL_USER, 4711, 0)) != nil || a.GetEntry(NewEntry(TAG_ACL_USER, 4712, 0)) == nil {
+		t.Errorf("expected user 4711 remapped to 4712, got %s", a.String())
+	}
+}
@@ -0,0 +1,50 @@
+package acls
+
+import (
+	"errors"
+	"os/user"
+	"strconv"
+)
+
+// IDResolver resolves the qualifiers of named USER and GROUP entries
+// against a user database
+type IDResolver interface {
+	// UserExists reports whether a user with the given uid exists
+	UserExists(uid uint32) (bool, error)
+	// GroupExists reports whether a group with the given gid exists
+	GroupExists(gid uint32) (bool, error)
+}
+
+// OSResolver is the IDResolver backed by the user database of the
+// system, as seen by os/user
+type OSResolver struct{}
+
+// UserExists reports whether a user with the given uid exists
+func (OSResolver) UserExists(uid uint32) (bool, error) {
+	_, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if errors.As(err, new(user.UnknownUserIdError)) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GroupExists reports whether a group with the given gid exists
+func (OSResolver) GroupExists(gid uint32) (bool, error) {
+	_, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10))
+	if errors.As(err, new(user.UnknownGroupIdError)) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// entryPrincipalExists reports whether the principal referenced by a
+// named USER or GROUP entry exists. Other entries always exist.
+func entryPrincipalExists(r IDResolver, e *ACLEntry) (bool, error) {
+	switch e.tag {
+	case TAG_ACL_USER:
+		return r.UserExists(e.id)
+	case TAG_ACL_GROUP:
+		return r.GroupExists(e.id)
+	}
+	return true, nil
+}
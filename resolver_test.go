@@ -0,0 +1,29 @@
+package acls
+
+import "testing"
+
+func TestOSResolver(t *testing.T) {
+	r := OSResolver{}
+	tests := []struct {
+		name   string
+		exists func(uint32) (bool, error)
+		id     uint32
+		want   bool
+	}{
+		{name: "root user", exists: r.UserExists, id: 0, want: true},
+		{name: "unknown user", exists: r.UserExists, id: 3999999999, want: false},
+		{name: "root group", exists: r.GroupExists, id: 0, want: true},
+		{name: "unknown group", exists: r.GroupExists, id: 3999999999, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.exists(tt.id)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("exists(%d) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
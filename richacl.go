@@ -0,0 +1,358 @@
+package acls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// RICHACL_XATTR_VERSION is the version of the system.richacl encoding
+const RICHACL_XATTR_VERSION = 0
+
+// RichACL flags
+const (
+	RICHACL_AUTO_INHERIT  uint8 = 0x01
+	RICHACL_PROTECTED     uint8 = 0x02
+	RICHACL_DEFAULTED     uint8 = 0x04
+	RICHACL_WRITE_THROUGH uint8 = 0x40
+	RICHACL_MASKED        uint8 = 0x80
+)
+
+// RICHACE_SPECIAL_WHO marks ACEs whose ID is one of the special IDs
+// below instead of a uid or gid. All other flags, the types and access
+// mask bits of richacls are the ones of NFSv4 ACLs.
+const RICHACE_SPECIAL_WHO NFS4ACEFlag = 0x4000
+
+// Special IDs of RichACEs
+const (
+	RICHACE_OWNER_SPECIAL_ID    = 0
+	RICHACE_GROUP_SPECIAL_ID    = 1
+	RICHACE_EVERYONE_SPECIAL_ID = 2
+)
+
+// RichACE is an access control entry of a RichACL
+type RichACE struct {
+	Type  NFS4ACEType
+	Flags NFS4ACEFlag
+	Mask  NFS4AccessMask
+	// ID is a uid, a gid with NFS4_ACE_IDENTIFIER_GROUP set or a
+	// special ID with RICHACE_SPECIAL_WHO set
+	ID uint32
+}
+
+// Tag returns the POSIX tag corresponding to the principal of the ACE,
+// like NFS4ACE.Tag does
+func (e *RichACE) Tag() Tag {
+	if e.Flags&RICHACE_SPECIAL_WHO != 0 {
+		switch e.ID {
+		case RICHACE_OWNER_SPECIAL_ID:
+			return TAG_ACL_USER_OBJ
+		case RICHACE_GROUP_SPECIAL_ID:
+			return TAG_ACL_GROUP_OBJ
+		case RICHACE_EVERYONE_SPECIAL_ID:
+			return TAG_ACL_EVERYONE
+		}
+		return TAG_ACL_UNDEFINED_FIELD
+	}
+	if e.Flags&NFS4_ACE_IDENTIFIER_GROUP != 0 {
+		return TAG_ACL_GROUP
+	}
+	return TAG_ACL_USER
+}
+
+// who returns the principal of the ACE as used by NFSv4 ACLs
+// with numeric IDs
+func (e *RichACE) who() string {
+	switch e.Tag() {
+	case TAG_ACL_USER_OBJ:
+		return NFS4WhoOwner
+	case TAG_ACL_GROUP_OBJ:
+		return NFS4WhoGroup
+	case TAG_ACL_EVERYONE:
+		return NFS4WhoEveryone
+	}
+	return strconv.FormatUint(uint64(e.ID), 10)
+}
+
+// String returns a string representation of the RichACE
+func (e *RichACE) String() string {
+	return fmt.Sprintf("Type: %5s, Flags: 0x%04x, Mask: 0x%06x, Who: %s", e.Type, uint32(e.Flags), uint32(e.Mask), e.who())
+}
+
+// RichACL is a richacl as stored in the system.richacl xattr. Like
+// NFSv4 ACLs the ACEs are evaluated in order. With RICHACL_MASKED set,
+// the file masks limit the permissions of the owner, group and other
+// file classes.
+type RichACL struct {
+	Flags     uint8
+	OwnerMask NFS4AccessMask
+	GroupMask NFS4AccessMask
+	OtherMask NFS4AccessMask
+	ACEs      []*RichACE
+}
+
+// Load loads the richacl of the given filepath
+func (a *RichACL) Load(fsPath string) error {
+	return readXattr(fsPath, RichACLAttr, a.parse)
+}
+
+// Apply applies the richacl to the given filepath
+func (a *RichACL) Apply(fsPath string) error {
+	b := &bytes.Buffer{}
+	a.ToByteSlice(b)
+	return unix.Setxattr(fsPath, string(RichACLAttr), b.Bytes(), 0)
+}
+
+// ToByteSlice writes the little endian encoding of the ACL,
+// as used by the system.richacl xattr, to result
+func (a *RichACL) ToByteSlice(result *bytes.Buffer) {
+	result.WriteByte(RICHACL_XATTR_VERSION)
+	result.WriteByte(a.Flags)
+	binary.Write(result, binary.LittleEndian, uint16(len(a.ACEs)))
+	binary.Write(result, binary.LittleEndian, uint32(a.OwnerMask))
+	binary.Write(result, binary.LittleEndian, uint32(a.GroupMask))
+	binary.Write(result, binary.LittleEndian, uint32(a.OtherMask))
+	for _, e := range a.ACEs {
+		binary.Write(result, binary.LittleEndian, uint16(e.Type))
+		binary.Write(result, binary.LittleEndian, uint16(e.Flags))
+		binary.Write(result, binary.LittleEndian, uint32(e.Mask))
+		binary.Write(result, binary.LittleEndian, e.ID)
+	}
+}
+
+// parse parses the encoded richacl and replaces the content of a
+func (a *RichACL) parse(b []byte) error {
+	if len(b) < 16 {
+		return fmt.Errorf("malformed richacl: expected a 16 byte header, got %d bytes", len(b))
+	}
+	if b[0] != RICHACL_XATTR_VERSION {
+		return fmt.Errorf("unsupported richacl version %d", b[0])
+	}
+	count := int(binary.LittleEndian.Uint16(b[2:4]))
+	if len(b) != 16+count*12 {
+		return fmt.Errorf("malformed richacl: %d aces need %d bytes, got %d", count, 16+count*12, len(b))
+	}
+	a.Flags = b[1]
+	a.OwnerMask = NFS4AccessMask(binary.LittleEndian.Uint32(b[4:8]))
+	a.GroupMask = NFS4AccessMask(binary.LittleEndian.Uint32(b[8:12]))
+	a.OtherMask = NFS4AccessMask(binary.LittleEndian.Uint32(b[12:16]))
+	a.ACEs = make([]*RichACE, 0, count)
+	for p := b[16:]; len(p) > 0; p = p[12:] {
+		a.ACEs = append(a.ACEs, &RichACE{
+			Type:  NFS4ACEType(binary.LittleEndian.Uint16(p[0:2])),
+			Flags: NFS4ACEFlag(binary.LittleEndian.Uint16(p[2:4])),
+			Mask:  NFS4AccessMask(binary.LittleEndian.Uint32(p[4:8])),
+			ID:    binary.LittleEndian.Uint32(p[8:12]),
+		})
+	}
+	return nil
+}
+
+// Equal returns true if both richacls are identical,
+// including the order of the ACEs
+func (a *RichACL) Equal(e *RichACL) bool {
+	if a.Flags != e.Flags || a.OwnerMask != e.OwnerMask || a.GroupMask != e.GroupMask ||
+		a.OtherMask != e.OtherMask || len(a.ACEs) != len(e.ACEs) {
+		return false
+	}
+	for i := range a.ACEs {
+		if *a.ACEs[i] != *e.ACEs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a human readable form of the RichACL
+func (a *RichACL) String() string {
+	sb := &strings.Builder{}
+	for _, e := range a.ACEs {
+		sb.WriteString(e.String())
+		sb.WriteString("\n")
+	}
+	return fmt.Sprintf("Flags: 0x%02x\nMasks: owner 0x%06x, group 0x%06x, other 0x%06x\nACEs:\n%s",
+		a.Flags, uint32(a.OwnerMask), uint32(a.GroupMask), uint32(a.OtherMask), sb.String())
+}
+
+// Allowed reports whether the richacl grants all requested access mask
+// bits to the credential, following the richacl permission check of
+// the Linux richacl patches. owner and group are the UID and GID of the
+// file. Capabilities, like those of root, are not considered.
+func (a *RichACL) Allowed(owner, group uint32, cred Credential, want NFS4AccessMask) bool {
+	masked := a.Flags&RICHACL_MASKED != 0
+	isOwner := cred.UID == owner
+	inOwningGroup := slices.Contains(cred.GIDs, group)
+	if masked && a.Flags&RICHACL_WRITE_THROUGH != 0 && isOwner {
+		return want&^a.OwnerMask == 0
+	}
+	// without masks the file class does not matter
+	inOwnerOrGroupClass := inOwningGroup || !masked
+
+	remaining := want
+	var denied NFS4AccessMask
+	for _, e := range a.ACEs {
+		if e.Flags&NFS4_ACE_INHERIT_ONLY_ACE != 0 {
+			continue
+		}
+		if e.Type != NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE && e.Type != NFS4_ACE_ACCESS_DENIED_ACE_TYPE {
+			continue
+		}
+		mask := e.Mask
+		switch e.Tag() {
+		case TAG_ACL_USER_OBJ:
+			if !isOwner {
+				continue
+			}
+			inOwnerOrGroupClass = true
+		case TAG_ACL_USER:
+			if cred.UID != e.ID {
+				continue
+			}
+			if !isOwner && masked && e.Type == NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE {
+				mask &= a.GroupMask
+			}
+			inOwnerOrGroupClass = true
+		case TAG_ACL_GROUP_OBJ, TAG_ACL_GROUP:
+			gid := group
+			if e.Tag() == TAG_ACL_GROUP {
+				gid = e.ID
+			}
+			if !slices.Contains(cred.GIDs, gid) {
+				continue
+			}
+			if masked && e.Type == NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE {
+				mask &= a.GroupMask
+			}
+			inOwnerOrGroupClass = true
+		case TAG_ACL_EVERYONE:
+		default:
+			continue
+		}
+
+		if e.Type == NFS4_ACE_ACCESS_DENIED_ACE_TYPE {
+			denied |= mask & remaining
+		}
+		remaining &^= mask
+		// keep going until the file class is known
+		if remaining == 0 && inOwnerOrGroupClass {
+			break
+		}
+	}
+	denied |= remaining
+
+	if masked {
+		switch {
+		case isOwner:
+			denied |= ^a.OwnerMask
+		case inOwnerOrGroupClass:
+			denied |= ^a.GroupMask
+		case a.Flags&RICHACL_WRITE_THROUGH != 0:
+			denied = ^a.OtherMask
+		default:
+			denied |= ^a.OtherMask
+		}
+	}
+	return denied&want == 0
+}
+
+// EffectiveMask returns the access mask bits the richacl grants to the
+// credential, each bit checked on its own via Allowed
+func (a *RichACL) EffectiveMask(owner, group uint32, cred Credential) NFS4AccessMask {
+	var result NFS4AccessMask
+	for bit := NFS4AccessMask(1); bit != 0; bit <<= 1 {
+		if a.Allowed(owner, group, cred, bit) {
+			result |= bit
+		}
+	}
+	return result
+}
+
+// RichACLFromPOSIX converts the access ACL and, for directories, the
+// default ACL to a richacl. The ACEs are the ones of POSIXToNFS4, the
+// file masks are set to what the ACL grants the file classes, without
+// enabling RICHACL_MASKED. def may be nil.
+func RichACLFromPOSIX(access, def *ACL, isDir bool) (*RichACL, error) {
+	n, err := POSIXToNFS4(access, def, isDir, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := summarizePOSIX(access.sortedEntries())
+	result := &RichACL{
+		OwnerMask: nfs4AllowMask(s.owner, isDir, true),
+		GroupMask: nfs4AllowMask(s.users|s.group|s.groups, isDir, false),
+		OtherMask: nfs4AllowMask(s.other, isDir, false),
+		ACEs:      make([]*RichACE, 0, len(n.ACEs)),
+	}
+	for _, e := range n.ACEs {
+		r := &RichACE{Type: e.Type, Flags: e.Flags &^ NFS4_ACE_IDENTIFIER_GROUP, Mask: e.Mask}
+		switch e.Tag() {
+		case TAG_ACL_USER_OBJ:
+			r.Flags |= RICHACE_SPECIAL_WHO
+			r.ID = RICHACE_OWNER_SPECIAL_ID
+		case TAG_ACL_GROUP_OBJ:
+			r.Flags |= RICHACE_SPECIAL_WHO
+			r.ID = RICHACE_GROUP_SPECIAL_ID
+		case TAG_ACL_EVERYONE:
+			r.Flags |= RICHACE_SPECIAL_WHO
+			r.ID = RICHACE_EVERYONE_SPECIAL_ID
+		default:
+			r.Flags |= e.Flags & NFS4_ACE_IDENTIFIER_GROUP
+			if r.ID, err = (NumericWhoMapper{}).ID(e.Tag(), e.Who); err != nil {
+				return nil, err
+			}
+		}
+		result.ACEs = append(result.ACEs, r)
+	}
+	return result, nil
+}
+
+// ToPOSIX converts the richacl to a POSIX access ACL and, for
+// directories, a default ACL, like NFS4ToPOSIX does. With
+// RICHACL_MASKED set, the file masks are applied to the owner, the
+// mask and other entries of the access ACL. Everything that cannot be
+// represented exactly is listed in the returned losses.
+func (a *RichACL) ToPOSIX(isDir bool) (access, def *ACL, losses []*NFS4Loss) {
+	n := &NFS4ACL{ACEs: make([]*NFS4ACE, 0, len(a.ACEs))}
+	for _, e := range a.ACEs {
+		flags := e.Flags &^ RICHACE_SPECIAL_WHO
+		if e.Tag() == TAG_ACL_GROUP_OBJ {
+			flags |= NFS4_ACE_IDENTIFIER_GROUP
+		}
+		n.ACEs = append(n.ACEs, &NFS4ACE{Type: e.Type, Flags: flags, Mask: e.Mask, Who: e.who()})
+	}
+	access, def, losses = NFS4ToPOSIX(n, isDir, nil)
+	if a.Flags&RICHACL_MASKED == 0 {
+		return access, def, losses
+	}
+
+	ownerPerm := posixPerm(a.OwnerMask, isDir)
+	groupPerm := posixPerm(a.GroupMask, isDir)
+	otherPerm := posixPerm(a.OtherMask, isDir)
+	for _, e := range access.GetEntries() {
+		switch e.tag {
+		case TAG_ACL_USER_OBJ:
+			if a.Flags&RICHACL_WRITE_THROUGH != 0 {
+				access.AddEntry(e.WithExactPerm(ownerPerm))
+			} else {
+				access.AddEntry(e.WithExactPerm(e.perm & ownerPerm))
+			}
+		case TAG_ACL_OTHER:
+			if a.Flags&RICHACL_WRITE_THROUGH != 0 {
+				access.AddEntry(e.WithExactPerm(otherPerm))
+			} else {
+				access.AddEntry(e.WithExactPerm(e.perm & otherPerm))
+			}
+		}
+	}
+	var mask uint16 = PermAll
+	if m := access.GetEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0)); m != nil {
+		mask = m.perm
+	}
+	access.AddEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, mask&groupPerm))
+	return access, def, losses
+}
@@ -0,0 +1,158 @@
+package acls
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// richACLFixture is an encoded masked richacl with three ACEs
+const richACLFixture = "00" + "80" + "0300" + "bf011600" + "a1001200" + "81001200" +
+	// allow owner@
+	"0000" + "0040" + "bf011600" + "00000000" +
+	// deny group 1000 write
+	"0100" + "4000" + "02000000" + "e8030000" +
+	// allow everyone@
+	"0000" + "0040" + "a1001200" + "02000000"
+
+func TestRichACL_parse(t *testing.T) {
+	b, _ := hex.DecodeString(richACLFixture)
+	want := &RichACL{
+		Flags:     RICHACL_MASKED,
+		OwnerMask: 0x1601bf,
+		GroupMask: 0x1200a1,
+		OtherMask: 0x120081,
+		ACEs: []*RichACE{
+			{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: RICHACE_SPECIAL_WHO, Mask: 0x1601bf, ID: RICHACE_OWNER_SPECIAL_ID},
+			{Type: NFS4_ACE_ACCESS_DENIED_ACE_TYPE, Flags: NFS4_ACE_IDENTIFIER_GROUP, Mask: NFS4_ACE_WRITE_DATA, ID: 1000},
+			{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: RICHACE_SPECIAL_WHO, Mask: 0x1200a1, ID: RICHACE_EVERYONE_SPECIAL_ID},
+		},
+	}
+	a := &RichACL{}
+	if err := a.parse(b); err != nil {
+		t.Fatalf("parse() unexpected error %v", err)
+	}
+	if !a.Equal(want) {
+		t.Errorf("parse() = %v, want %v", a, want)
+	}
+	wantTags := []Tag{TAG_ACL_USER_OBJ, TAG_ACL_GROUP, TAG_ACL_EVERYONE}
+	for i, e := range a.ACEs {
+		if e.Tag() != wantTags[i] {
+			t.Errorf("ACE %d Tag() = %s, want %s", i, Tag2String(e.Tag()), Tag2String(wantTags[i]))
+		}
+	}
+	buf := &bytes.Buffer{}
+	a.ToByteSlice(buf)
+	if got := hex.EncodeToString(buf.Bytes()); got != richACLFixture {
+		t.Errorf("ToByteSlice() = %s, want %s", got, richACLFixture)
+	}
+
+	for name, input := range map[string]string{
+		"short header":    "0080",
+		"wrong version":   "01" + richACLFixture[2:],
+		"truncated aces":  richACLFixture[:len(richACLFixture)-8],
+		"trailing bytes":  richACLFixture + "00",
+		"count too large": "00800400" + richACLFixture[8:],
+	} {
+		b, _ := hex.DecodeString(input)
+		if err := (&RichACL{}).parse(b); err == nil {
+			t.Errorf("%s: parse() expected error", name)
+		}
+	}
+}
+
+func TestRichACL_Allowed(t *testing.T) {
+	b, _ := hex.DecodeString(richACLFixture)
+	a := &RichACL{}
+	if err := a.parse(b); err != nil {
+		t.Fatalf("parse() unexpected error %v", err)
+	}
+	writeThrough := &RichACL{Flags: RICHACL_MASKED | RICHACL_WRITE_THROUGH, OwnerMask: NFS4_ACE_READ_DATA, OtherMask: NFS4_ACE_EXECUTE,
+		ACEs: []*RichACE{{Type: NFS4_ACE_ACCESS_ALLOWED_ACE_TYPE, Flags: RICHACE_SPECIAL_WHO, Mask: NFS4_ACE_WRITE_DATA, ID: RICHACE_EVERYONE_SPECIAL_ID}}}
+
+	tests := []struct {
+		name string
+		acl  *RichACL
+		cred Credential
+		want NFS4AccessMask
+		ok   bool
+	}{
+		{name: "owner", acl: a, cred: Credential{UID: 1}, want: NFS4_ACE_WRITE_DATA | NFS4_ACE_WRITE_ACL, ok: true},
+		{name: "denied group before everyone", acl: a, cred: Credential{UID: 5, GIDs: []uint32{1000}}, want: NFS4_ACE_WRITE_DATA, ok: false},
+		{name: "group class masked", acl: a, cred: Credential{UID: 5, GIDs: []uint32{1000}}, want: NFS4_ACE_EXECUTE, ok: true},
+		{name: "other class masked", acl: a, cred: Credential{UID: 5}, want: NFS4_ACE_EXECUTE, ok: false},
+		{name: "other class within mask", acl: a, cred: Credential{UID: 5}, want: NFS4_ACE_READ_DATA, ok: true},
+		{name: "write through owner", acl: writeThrough, cred: Credential{UID: 1}, want: NFS4_ACE_READ_DATA, ok: true},
+		{name: "write through other", acl: writeThrough, cred: Credential{UID: 5}, want: NFS4_ACE_EXECUTE, ok: true},
+		{name: "write through other ace ignored", acl: writeThrough, cred: Credential{UID: 5}, want: NFS4_ACE_WRITE_DATA, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.acl.Allowed(1, 100, tt.cred, tt.want); got != tt.ok {
+				t.Errorf("Allowed() = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+	if got := a.EffectiveMask(1, 100, Credential{UID: 5}); got != 0x120081 {
+		t.Errorf("EffectiveMask() = 0x%x, want 0x120081", uint32(got))
+	}
+}
+
+func TestRichACL_POSIX(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	access := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_USER, 1000, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+		NewEntry(TAG_ACL_GROUP, 2000, 6),
+		NewEntry(TAG_ACL_MASK, u, 6),
+		NewEntry(TAG_ACL_OTHER, u, 4),
+	}}
+	def := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	}}
+	r, err := RichACLFromPOSIX(access, def, true)
+	if err != nil {
+		t.Fatalf("RichACLFromPOSIX() unexpected error %v", err)
+	}
+	if r.Flags&RICHACL_MASKED != 0 || r.GroupMask != nfs4AllowMask(6, true, false) {
+		t.Errorf("unexpected flags or masks %v", r)
+	}
+	// both evaluate the same
+	universe := newCredentialUniverse(access)
+	for _, uid := range append(universe.uids, universe.owner) {
+		for _, gids := range [][]uint32{nil, {universe.group}, {2000}, {2000, universe.group}} {
+			cred := Credential{UID: uid, GIDs: gids}
+			want := nfs4DenyMask(access.EffectivePerm(universe.owner, universe.group, cred), true)
+			if got := r.EffectiveMask(universe.owner, universe.group, cred) & want; got != want {
+				t.Errorf("credential %+v: richacl grants 0x%x, posix 0x%x", cred, uint32(got), uint32(want))
+			}
+		}
+	}
+
+	back, backDef, losses := r.ToPOSIX(true)
+	if len(losses) != 0 {
+		t.Errorf("ToPOSIX() unexpected losses %v", losses)
+	}
+	if !universe.equivalent(access, back) {
+		t.Errorf("ToPOSIX() = %v, not equivalent to %v", back, access)
+	}
+	if backDef == nil || !newCredentialUniverse(def).equivalent(def, backDef) {
+		t.Errorf("ToPOSIX() default = %v, not equivalent to %v", backDef, def)
+	}
+
+	// file masks become the POSIX mask
+	r.Flags |= RICHACL_MASKED
+	r.GroupMask = nfs4AllowMask(PermRead, true, false)
+	r.OtherMask = nfs4AllowMask(PermNone, true, false)
+	masked, _, _ := r.ToPOSIX(true)
+	if e := masked.GetEntry(NewEntry(TAG_ACL_MASK, ACL_UNDEFINED_ID, 0)); e == nil || e.Perm() != PermRead {
+		t.Errorf("expected mask r--, got %v", masked)
+	}
+	if e := masked.GetEntry(NewEntry(TAG_ACL_OTHER, ACL_UNDEFINED_ID, 0)); e == nil || e.Perm() != PermNone {
+		t.Errorf("expected other ---, got %v", masked)
+	}
+}
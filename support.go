@@ -0,0 +1,193 @@
+package acls
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ACLSupport describes whether the filesystem of a path supports
+// POSIX ACLs
+type ACLSupport struct {
+	// MountPoint is the mount point of the filesystem holding the path
+	MountPoint string
+	// FSType is the filesystem type as listed in /proc/self/mountinfo
+	FSType string
+	// Magic is the filesystem magic reported by statfs
+	Magic int64
+	// Options holds the mount and superblock options
+	Options []string
+	// Access and Default report the support of access and default ACLs
+	Access  bool
+	Default bool
+	// Probed is set if support was verified with getxattr,
+	// otherwise it is derived from the filesystem type and options
+	Probed bool
+}
+
+// noACLMagics lists filesystem magics that never support POSIX ACLs
+var noACLMagics = []int64{
+	unix.MSDOS_SUPER_MAGIC,
+	unix.EXFAT_SUPER_MAGIC,
+	unix.ISOFS_SUPER_MAGIC,
+	unix.UDF_SUPER_MAGIC,
+	unix.SQUASHFS_MAGIC,
+	unix.PROC_SUPER_MAGIC,
+	unix.SYSFS_MAGIC,
+	unix.DEVPTS_SUPER_MAGIC,
+	unix.CGROUP_SUPER_MAGIC,
+	unix.CGROUP2_SUPER_MAGIC,
+}
+
+// noACLTypes lists filesystem types that never support POSIX ACLs.
+// NFSv4 exposes NFSv4 ACLs instead.
+var noACLTypes = []string{"vfat", "msdos", "exfat", "iso9660", "udf", "squashfs", "proc", "sysfs", "devpts", "cgroup", "cgroup2", "nfs4"}
+
+// ProbeACLSupport determines whether the filesystem holding fsPath
+// supports access and default ACLs from /proc/self/mountinfo and the
+// statfs magic. Support depending on kernel configuration, like for
+// tmpfs, or on the implementation, like for FUSE, is assumed unless
+// probe is set. Then support is verified by reading the ACL xattrs of
+// fsPath, which modifies nothing. Default ACLs are probed on directories
+// only, for other paths they are reported like access ACLs.
+func ProbeACLSupport(fsPath string, probe bool) (*ACLSupport, error) {
+	fsPath, err := filepath.Abs(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	fsPath, err = filepath.EvalSymlinks(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	st := unix.Statfs_t{}
+	if err := unix.Statfs(fsPath, &st); err != nil {
+		return nil, &os.PathError{Op: "statfs", Path: fsPath, Err: err}
+	}
+	result := &ACLSupport{Magic: int64(st.Type)}
+
+	mounts, err := loadMountInfo("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	if m := findMount(mounts, fsPath); m != nil {
+		result.MountPoint = m.mountPoint
+		result.FSType = m.fsType
+		result.Options = m.options
+	}
+
+	supported := !slices.Contains(noACLMagics, result.Magic) &&
+		!slices.Contains(noACLTypes, result.FSType) &&
+		!slices.Contains(result.Options, "noacl")
+	result.Access, result.Default = supported, supported
+	if !probe {
+		return result, nil
+	}
+
+	result.Probed = true
+	if result.Access, err = probeXattr(fsPath, PosixACLAccess); err != nil {
+		return nil, err
+	}
+	result.Default = result.Access
+	if info, err := os.Stat(fsPath); err == nil && info.IsDir() {
+		if result.Default, err = probeXattr(fsPath, PosixACLDefault); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// probeXattr reports whether the ACL attr of fsPath can be read,
+// a missing attr still means the filesystem supports it
+func probeXattr(fsPath string, attr ACLAttr) (bool, error) {
+	_, err := unix.Getxattr(fsPath, string(attr), nil)
+	switch {
+	case err == nil, errors.Is(err, unix.ENODATA):
+		return true, nil
+	case errors.Is(err, unix.EOPNOTSUPP):
+		return false, nil
+	}
+	return false, &os.PathError{Op: "getxattr", Path: fsPath, Err: err}
+}
+
+// mountInfo is a line of /proc/self/mountinfo
+type mountInfo struct {
+	mountPoint string
+	fsType     string
+	options    []string
+}
+
+// loadMountInfo parses the mountinfo file at path
+func loadMountInfo(path string) ([]*mountInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+// parseMountInfo parses the format of /proc/<pid>/mountinfo, see proc(5)
+func parseMountInfo(r io.Reader) ([]*mountInfo, error) {
+	result := []*mountInfo{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// optional fields are terminated by a single hyphen
+		sep := slices.Index(fields, "-")
+		if sep < 6 || len(fields) < sep+4 {
+			return nil, fmt.Errorf("mountinfo line %d: malformed", line)
+		}
+		m := &mountInfo{
+			mountPoint: unescapeMountField(fields[4]),
+			fsType:     fields[sep+1],
+		}
+		m.options = append(strings.Split(fields[5], ","), strings.Split(fields[sep+3], ",")...)
+		result = append(result, m)
+	}
+	return result, scanner.Err()
+}
+
+// unescapeMountField decodes the octal escapes of
+// whitespace and backslashes in mountinfo fields
+func unescapeMountField(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// findMount returns the mount holding fsPath, which is the one with
+// the longest matching mount point. Of several mounts on the same
+// mount point the last one is visible.
+func findMount(mounts []*mountInfo, fsPath string) *mountInfo {
+	var result *mountInfo
+	for _, m := range mounts {
+		rel, err := filepath.Rel(m.mountPoint, fsPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		if result == nil || len(m.mountPoint) >= len(result.mountPoint) {
+			result = m
+		}
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package acls
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	input := `28 1 254:0 / / rw,relatime - ext4 /dev/vda rw,discard
+26 25 0:24 / /dev/shm rw,relatime shared:5 - tmpfs tmpfs rw,size=6147400k
+31 26 0:27 / /dev/shm rw,relatime - tmpfs tmpfs rw,size=1024k
+40 28 8:1 / /mnt/usb\040stick rw,noatime master:1 propagate_from:2 - vfat /dev/sda1 rw,fmask=0022
+41 28 8:2 / /data rw - ext4 /dev/sdb1 rw,noacl
+`
+	mounts, err := parseMountInfo(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseMountInfo() unexpected error %v", err)
+	}
+	if len(mounts) != 5 {
+		t.Fatalf("expected 5 mounts, got %d", len(mounts))
+	}
+	if want := (&mountInfo{mountPoint: "/mnt/usb stick", fsType: "vfat", options: []string{"rw", "noatime", "rw", "fmask=0022"}}); !reflect.DeepEqual(mounts[3], want) {
+		t.Errorf("got %+v, want %+v", mounts[3], want)
+	}
+
+	tests := []struct {
+		path string
+		want *mountInfo
+	}{
+		{path: "/root/module", want: mounts[0]},
+		{path: "/dev/shm/x", want: mounts[2]},
+		{path: "/dev/shmem", want: mounts[0]},
+		{path: "/mnt/usb stick/file", want: mounts[3]},
+		{path: "/data", want: mounts[4]},
+	}
+	for _, tt := range tests {
+		if got := findMount(mounts, tt.path); got != tt.want {
+			t.Errorf("findMount(%s) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := parseMountInfo(strings.NewReader("28 1 254:0 / /\n")); err == nil {
+		t.Errorf("expected error for malformed line")
+	}
+}
+
+func TestProbeACLSupport(t *testing.T) {
+	fsPath := createTempFile(t)
+	for _, probe := range []bool{false, true} {
+		s, err := ProbeACLSupport(fsPath, probe)
+		if err != nil {
+			t.Fatalf("ProbeACLSupport() unexpected error %v", err)
+		}
+		if s.MountPoint == "" || s.FSType == "" || s.Magic == 0 || s.Probed != probe {
+			t.Errorf("incomplete result %+v", s)
+		}
+		if !s.Access || !s.Default {
+			t.Errorf("expected ACL support for %s, got %+v", fsPath, s)
+		}
+	}
+
+	s, err := ProbeACLSupport("/proc/self", true)
+	if err != nil {
+		t.Skipf("procfs not available: %v", err)
+	}
+	if s.FSType != "proc" || s.Access || s.Default {
+		t.Errorf("expected no ACL support on procfs, got %+v", s)
+	}
+}
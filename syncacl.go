@@ -0,0 +1,97 @@
+package acls
+
+import "sync"
+
+// SyncACL wraps an ACL and guards it with a read/write lock,
+// so a single ACL can be shared and modified across goroutines.
+type SyncACL struct {
+	mu  sync.RWMutex
+	acl *ACL
+}
+
+// NewSyncACL returns a new SyncACL holding a clone of the given ACL.
+// If a is nil, an empty ACL is used.
+func NewSyncACL(a *ACL) *SyncACL {
+	if a == nil {
+		a = NewACL()
+	}
+	return &SyncACL{
+		acl: a.Clone(),
+	}
+}
+
+// Load loads the attr defined POSIX.ACL type (access or default)
+// from the given filepath, replacing the current entries
+func (s *SyncACL) Load(fsPath string, attr ACLAttr) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acl.Load(fsPath, attr)
+}
+
+// Apply applies the ACL to the given filesystem path
+func (s *SyncACL) Apply(fsPath string, attr ACLAttr) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl.Apply(fsPath, attr)
+}
+
+// AddEntry adds the given entry to the ACL, replacing
+// an existing entry with the same Tag and ID
+func (s *SyncACL) AddEntry(e *ACLEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acl.AddEntry(e)
+}
+
+// DeleteEntry deletes the entry that has the same tag and id
+// if it exists and returns the deleted entry
+func (s *SyncACL) DeleteEntry(e *ACLEntry) *ACLEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acl.DeleteEntry(e)
+}
+
+// GetEntry returns the entry with the given Tag and ID if it exists, nil otherwise
+func (s *SyncACL) GetEntry(e *ACLEntry) *ACLEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl.GetEntry(e)
+}
+
+// GetEntries returns all ACLEntries of the ACL
+func (s *SyncACL) GetEntries() []*ACLEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl.GetEntries()
+}
+
+// Equal returns true if the wrapped ACL equals the given ACL
+func (s *SyncACL) Equal(e *ACL) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl.Equal(e)
+}
+
+// String returns a human readable form of the ACL
+func (s *SyncACL) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl.String()
+}
+
+// Snapshot returns a deep copy of the current state of the ACL.
+// The snapshot is not affected by later modifications.
+func (s *SyncACL) Snapshot() *ACL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl.Clone()
+}
+
+// Update calls f with the wrapped ACL while holding the write lock,
+// allowing multiple modifications to happen atomically.
+// f must not retain the ACL after returning.
+func (s *SyncACL) Update(f func(a *ACL) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return f(s.acl)
+}
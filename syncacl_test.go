@@ -0,0 +1,83 @@
+package acls
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestACL_Clone(t *testing.T) {
+	orig := &ACL{
+		version: 2,
+		entries: []*ACLEntry{
+			NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+			NewEntry(TAG_ACL_GROUP_OBJ, 1000, 5),
+			NewEntry(TAG_ACL_OTHER, math.MaxUint32, 0),
+		},
+	}
+
+	clone := orig.Clone()
+	if !clone.Equal(orig) {
+		t.Fatalf("expected clone to equal original, got %s", clone.String())
+	}
+	for i := range orig.entries {
+		if orig.entries[i] == clone.entries[i] {
+			t.Errorf("entry %d is shared between original and clone", i)
+		}
+	}
+
+	clone.AddEntry(NewEntry(TAG_ACL_USER, 2000, 6))
+	clone.DeleteEntry(NewEntry(TAG_ACL_OTHER, math.MaxUint32, 0))
+	if len(orig.entries) != 3 {
+		t.Errorf("modifying the clone changed the original: %s", orig.String())
+	}
+}
+
+func TestACL_ReadersDoNotMutate(t *testing.T) {
+	entries := []*ACLEntry{
+		NewEntry(TAG_ACL_OTHER, math.MaxUint32, 0),
+		NewEntry(TAG_ACL_USER, 2000, 6),
+		NewEntry(TAG_ACL_USER_OBJ, 1000, 7),
+	}
+	a := &ACL{version: 2, entries: append([]*ACLEntry{}, entries...)}
+
+	_ = a.String()
+	_ = a.Equal(a.Clone())
+
+	for i, e := range entries {
+		if a.entries[i] != e {
+			t.Errorf("position %d changed by a read only method: got %s, want %s", i, a.entries[i].String(), e.String())
+		}
+	}
+}
+
+func TestSyncACL_Concurrent(t *testing.T) {
+	s := NewSyncACL(NewACL())
+	template := s.Snapshot()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(id uint32) {
+			defer wg.Done()
+			s.AddEntry(NewEntry(TAG_ACL_USER, id, 7))
+			_ = s.String()
+			_ = s.Equal(template)
+			_ = s.GetEntries()
+			err := s.Update(func(a *ACL) error {
+				return a.AddEntry(NewEntry(TAG_ACL_GROUP, id, 5))
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(uint32(i))
+	}
+	wg.Wait()
+
+	if got := len(s.GetEntries()); got != 32 {
+		t.Errorf("expected 32 entries, got %d", got)
+	}
+	if len(template.entries) != 0 {
+		t.Errorf("snapshot was modified by later updates")
+	}
+}
@@ -0,0 +1,175 @@
+package acls
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PAX record keys holding ACLs in tar archives
+const (
+	// PAXSchilyACLAccess holds the access ACL in text form,
+	// as written by star and GNU tar --acls
+	PAXSchilyACLAccess = "SCHILY.acl.access"
+	// PAXSchilyACLDefault holds the default ACL in text form
+	PAXSchilyACLDefault = "SCHILY.acl.default"
+	// PAXSchilyXattrPrefix prefixes records holding raw xattrs,
+	// as written by GNU tar --xattrs and bsdtar
+	PAXSchilyXattrPrefix = "SCHILY.xattr."
+)
+
+// TarACLFormat selects the PAX records ACLs are stored in
+type TarACLFormat int
+
+const (
+	// TarACLText stores ACLs as SCHILY.acl.access and SCHILY.acl.default text
+	TarACLText TarACLFormat = iota
+	// TarACLXattr stores ACLs as binary SCHILY.xattr.system.posix_acl_* xattrs
+	TarACLXattr
+)
+
+// PAXText returns the ACL in the text form of the SCHILY.acl records,
+// e.g. "user::rw-,user:1000:r--,group::r--,mask::r--,other::---".
+// Qualifiers are numeric IDs.
+func (a *ACL) PAXText() string {
+	parts := []string{}
+	for _, e := range a.sortedEntries() {
+		tag := ""
+		switch e.tag {
+		case TAG_ACL_USER_OBJ, TAG_ACL_USER:
+			tag = "user"
+		case TAG_ACL_GROUP_OBJ, TAG_ACL_GROUP:
+			tag = "group"
+		case TAG_ACL_MASK:
+			tag = "mask"
+		case TAG_ACL_OTHER:
+			tag = "other"
+		default:
+			continue
+		}
+		qualifier := ""
+		if e.tag == TAG_ACL_USER || e.tag == TAG_ACL_GROUP {
+			qualifier = strconv.FormatUint(uint64(e.id), 10)
+		}
+		parts = append(parts, fmt.Sprintf("%s:%s:%s", tag, qualifier, PermUintToString(e.perm)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParsePAXText parses the text form of the SCHILY.acl records. Entries
+// are separated by commas or newlines and "#" starts a comment.
+// Qualifiers are numeric IDs or names, a numeric ID appended as fourth
+// field, as written by star, takes precedence over the name.
+func ParsePAXText(s string) (*ACL, error) {
+	a := NewACL()
+	for _, line := range strings.Split(s, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		for _, field := range strings.Split(line, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			parts := strings.Split(field, ":")
+			if len(parts) == 4 {
+				if _, err := strconv.ParseUint(parts[3], 10, 32); err != nil || parts[1] == "" {
+					return nil, fmt.Errorf("invalid pax acl entry %q, unexpected fourth field", field)
+				}
+				parts = []string{parts[0], parts[3], parts[2]}
+			}
+			spec, err := ParseEntrySpec(strings.Join(parts, ":"))
+			if err != nil {
+				return nil, err
+			}
+			if spec.CondExecute {
+				return nil, fmt.Errorf("invalid pax acl entry %q, unknown permission 'X'", field)
+			}
+			a.AddEntry(spec.Entry(false, a))
+		}
+	}
+	if len(a.entries) == 0 {
+		return nil, fmt.Errorf("invalid pax acl %q, no entries", s)
+	}
+	return a, nil
+}
+
+// paxACLKey returns the PAX record key of attr in the given format
+func paxACLKey(attr ACLAttr, format TarACLFormat) (string, error) {
+	switch attr {
+	case PosixACLAccess, PosixACLDefault:
+	default:
+		return "", fmt.Errorf("unsupported acl attr %q", attr)
+	}
+	switch format {
+	case TarACLText:
+		if attr == PosixACLAccess {
+			return PAXSchilyACLAccess, nil
+		}
+		return PAXSchilyACLDefault, nil
+	case TarACLXattr:
+		return PAXSchilyXattrPrefix + string(attr), nil
+	}
+	return "", fmt.Errorf("unknown tar acl format %d", format)
+}
+
+// SetTarACL stores the ACL as attr in the PAX records of the header,
+// replacing records of either format, and switches the header to the
+// PAX format. A nil ACL removes the records of attr.
+func SetTarACL(h *tar.Header, attr ACLAttr, a *ACL, format TarACLFormat) error {
+	key, err := paxACLKey(attr, format)
+	if err != nil {
+		return err
+	}
+	var value string
+	switch {
+	case a == nil:
+	case format == TarACLText:
+		value = a.PAXText()
+	default:
+		buf := &bytes.Buffer{}
+		if err := a.ToByteSlice(buf); err != nil {
+			return err
+		}
+		value = buf.String()
+	}
+	for _, f := range []TarACLFormat{TarACLText, TarACLXattr} {
+		k, _ := paxACLKey(attr, f)
+		delete(h.PAXRecords, k)
+	}
+	if a == nil {
+		return nil
+	}
+	if h.PAXRecords == nil {
+		h.PAXRecords = map[string]string{}
+	}
+	h.PAXRecords[key] = value
+	h.Format = tar.FormatPAX
+	return nil
+}
+
+// TarACL returns the ACL stored as attr in the PAX records of the
+// header, nil if there is none. The text form is preferred if both
+// formats are present.
+func TarACL(h *tar.Header, attr ACLAttr) (*ACL, error) {
+	key, err := paxACLKey(attr, TarACLText)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := h.PAXRecords[key]; ok {
+		a, err := ParsePAXText(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s of %s: %w", key, h.Name, err)
+		}
+		return a, nil
+	}
+	key, _ = paxACLKey(attr, TarACLXattr)
+	if v, ok := h.PAXRecords[key]; ok {
+		a := NewACL()
+		if err := a.parse([]byte(v)); err != nil {
+			return nil, fmt.Errorf("%s of %s: %w", key, h.Name, err)
+		}
+		return a, nil
+	}
+	return nil, nil
+}
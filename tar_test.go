@@ -0,0 +1,148 @@
+package acls
+
+import (
+	"archive/tar"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParsePAXText(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	want := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 6),
+		NewEntry(TAG_ACL_USER, 1000, 4),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+		NewEntry(TAG_ACL_GROUP, 2000, 7),
+		NewEntry(TAG_ACL_MASK, u, 7),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	} {
+		want.AddEntry(e)
+	}
+	text := "user::rw-,user:1000:r--,group::r--,group:2000:rwx,mask::rwx,other::---"
+	if got := want.PAXText(); got != text {
+		t.Errorf("PAXText() = %s, want %s", got, text)
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{name: "gnu tar", text: text},
+		{name: "short tags", text: "u::rw-,u:1000:r--,g::r--,g:2000:rwx,m::rwx,o::---"},
+		{name: "star numeric ids", text: "user::rw-,user:nosuchuser:r--:1000,group::r--,group:nosuchgroup:rwx:2000,mask::rwx,other::---"},
+		{name: "lines and comments", text: "# file: x\nuser::rw-\nuser:1000:r--\ngroup::r--\ngroup:2000:rwx # team\nmask::rwx\nother::---\n"},
+		{name: "empty", text: "# nothing", wantErr: "no entries"},
+		{name: "conditional execute", text: "user::rwX", wantErr: "unknown permission"},
+		{name: "invalid fourth field", text: "user::rw-:1000", wantErr: "fourth field"},
+		{name: "unknown type", text: "everyone::rwx", wantErr: "unknown type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePAXText(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParsePAXText() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePAXText() error = %v", err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("ParsePAXText() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestTarACL(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	access := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_USER, 1000, 5),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+		NewEntry(TAG_ACL_MASK, u, 5),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	} {
+		access.AddEntry(e)
+	}
+	def := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+		NewEntry(TAG_ACL_OTHER, u, 5),
+	} {
+		def.AddEntry(e)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		format TarACLFormat
+		key    string
+	}{
+		{"text", TarACLText, PAXSchilyACLAccess},
+		{"xattr", TarACLXattr, "SCHILY.xattr.system.posix_acl_access"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}
+			if err := SetTarACL(h, PosixACLAccess, access, tc.format); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetTarACL(h, PosixACLDefault, def, tc.format); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := h.PAXRecords[tc.key]; !ok {
+				t.Fatalf("PAXRecords = %v, want key %s", h.PAXRecords, tc.key)
+			}
+
+			// round trip through an archive
+			buf := &bytes.Buffer{}
+			tw := tar.NewWriter(buf)
+			if err := tw.WriteHeader(h); err != nil {
+				t.Fatal(err)
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tar.NewReader(buf).Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []struct {
+				attr ACLAttr
+				acl  *ACL
+			}{{PosixACLAccess, access}, {PosixACLDefault, def}} {
+				a, err := TarACL(got, want.attr)
+				if err != nil {
+					t.Fatalf("TarACL(%s) error = %v", want.attr, err)
+				}
+				if a == nil || !a.Equal(want.acl) {
+					t.Errorf("TarACL(%s) = %v, want %s", want.attr, a, want.acl)
+				}
+			}
+		})
+	}
+
+	h := &tar.Header{Name: "file"}
+	SetTarACL(h, PosixACLAccess, access, TarACLXattr)
+	SetTarACL(h, PosixACLAccess, access, TarACLText)
+	if len(h.PAXRecords) != 1 {
+		t.Errorf("PAXRecords = %v, want only the text record", h.PAXRecords)
+	}
+	SetTarACL(h, PosixACLAccess, nil, TarACLText)
+	if a, err := TarACL(h, PosixACLAccess); a != nil || err != nil {
+		t.Errorf("TarACL() after removal = %v, %v", a, err)
+	}
+	if err := SetTarACL(h, NFS4ACLAttr, access, TarACLText); err == nil {
+		t.Error("SetTarACL() with nfs4 attr succeeded")
+	}
+	h.PAXRecords = map[string]string{PAXSchilyACLAccess: "bogus"}
+	if _, err := TarACL(h, PosixACLAccess); err == nil {
+		t.Error("TarACL() with invalid record succeeded")
+	}
+}
@@ -0,0 +1,329 @@
+package acls
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ExtractOptions controls ExtractTar
+type ExtractOptions struct {
+	// PreserveOwner sets the uid and gid of the entries from the
+	// archive, which usually requires root
+	PreserveOwner bool
+	// SkipUnsupported ignores ACLs if the target filesystem
+	// does not support them, instead of failing
+	SkipUnsupported bool
+	// Logger receives debug events, nothing is logged if nil
+	Logger *slog.Logger
+}
+
+// extractedDir is a directory whose mode and ACLs are set after
+// all entries are extracted, so restrictive permissions do not
+// prevent creating its content
+type extractedDir struct {
+	name string
+	hdr  *tar.Header
+}
+
+// ExtractTar extracts the archive read from r below dir and applies the
+// ACLs stored in the PAX records of every entry, see TarACL. Directories,
+// regular files, symlinks and hard links are extracted, other entries
+// are skipped. Entries must not leave dir, neither by their name nor
+// through symlinks. Modes and ACLs of directories are set once all
+// entries are extracted, or extraction failed. The setuid, setgid and
+// sticky bits are restored along with the permissions.
+func ExtractTar(ctx context.Context, r io.Reader, dir string, opts ExtractOptions) (err error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = discardLogger
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	dirs := []extractedDir{}
+	// directories extracted so far are finished on failure as well,
+	// rather than being left at 0700 without ACLs
+	defer func() {
+		err = errors.Join(err, finishDirs(root, dirs, opts, logger))
+	}()
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("tar entry %q: path outside of %s", hdr.Name, dir)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(name, 0o700); err != nil {
+				return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+			}
+			dirs = append(dirs, extractedDir{name: name, hdr: hdr})
+			continue
+		case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+			err = extractEntry(root, name, hdr, tr)
+		default:
+			logger.Debug("tar entry skipped", "name", hdr.Name, "type", hdr.Typeflag)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			// hard links share the inode and thus the ACLs of their target
+			continue
+		}
+		if err := finishTarEntry(root, name, hdr, opts, logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishDirs sets owner, mode and ACLs of the extracted directories,
+// deepest first, so parents stay writable while children are finished
+func finishDirs(root *os.Root, dirs []extractedDir, opts ExtractOptions, logger *slog.Logger) error {
+	errs := []error{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		info, err := root.Lstat(d.name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tar entry %q: %w", d.hdr.Name, err))
+			continue
+		}
+		if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("tar entry %q: no longer a directory", d.hdr.Name))
+			continue
+		}
+		if err := finishTarEntry(root, d.name, d.hdr, opts, logger); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// extractEntry creates a regular file, symlink or hard link, along
+// with missing parent directories, as archives often lack entries
+// for them
+func extractEntry(root *os.Root, name string, hdr *tar.Header, r io.Reader) error {
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		return root.Symlink(hdr.Linkname, name)
+	case tar.TypeLink:
+		return root.Link(filepath.Clean(filepath.FromSlash(hdr.Linkname)), name)
+	}
+	return extractFile(root, name, hdr, r)
+}
+
+// extractFile writes the content of a regular file
+func extractFile(root *os.Root, name string, hdr *tar.Header, r io.Reader) error {
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// finishTarEntry sets owner, mode and ACLs of an extracted entry.
+// They are set through a descriptor opened within root, so nothing
+// follows symlinks out of it.
+func finishTarEntry(root *os.Root, name string, hdr *tar.Header, opts ExtractOptions, logger *slog.Logger) error {
+	if hdr.Typeflag == tar.TypeSymlink {
+		// symlinks carry neither mode nor ACLs
+		if opts.PreserveOwner {
+			if err := root.Lchown(name, hdr.Uid, hdr.Gid); err != nil {
+				return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+			}
+		}
+		return nil
+	}
+
+	f, err := root.Open(name)
+	if err != nil {
+		return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+	}
+	if info.IsDir() != (hdr.Typeflag == tar.TypeDir) || !info.IsDir() && !info.Mode().IsRegular() {
+		return fmt.Errorf("tar entry %q: replaced by %v", hdr.Name, info.Mode().Type())
+	}
+
+	if opts.PreserveOwner {
+		if err := f.Chown(hdr.Uid, hdr.Gid); err != nil {
+			return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+		}
+	}
+	// chmod after chown, which clears the setuid and setgid bits
+	mode := hdr.FileInfo().Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	if err := f.Chmod(mode); err != nil {
+		return fmt.Errorf("tar entry %q: %w", hdr.Name, err)
+	}
+
+	attrs := []ACLAttr{PosixACLAccess}
+	if hdr.Typeflag == tar.TypeDir {
+		attrs = append(attrs, PosixACLDefault)
+	}
+	for _, attr := range attrs {
+		a, err := TarACL(hdr, attr)
+		if err != nil {
+			return err
+		}
+		if a == nil {
+			continue
+		}
+		a.SetLogger(logger)
+		err = a.applyFd(int(f.Fd()), attr)
+		if opts.SkipUnsupported && errors.Is(err, unix.EOPNOTSUPP) {
+			logger.Debug("acl not supported, skipped", "name", hdr.Name, "attr", attr)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("tar entry %q %s: %w", hdr.Name, attr, err)
+		}
+	}
+	return nil
+}
+
+// TarWriter wraps a tar.Writer and stores the ACLs of the archived
+// files in the PAX records of their headers
+type TarWriter struct {
+	*tar.Writer
+	// Format selects the PAX records the ACLs are stored in
+	Format TarACLFormat
+	// Logger receives debug events, nothing is logged if nil
+	Logger *slog.Logger
+}
+
+// NewTarWriter returns a TarWriter writing to w
+func NewTarWriter(w io.Writer, format TarACLFormat) *TarWriter {
+	return &TarWriter{Writer: tar.NewWriter(w), Format: format}
+}
+
+// WriteHeaderFor adds the ACLs of the file at fsPath to the header and
+// writes it. Only ACLs actually present are added, the default ACL
+// only for directories. Filesystems without ACL support and symlinks
+// yield no ACLs.
+func (w *TarWriter) WriteHeaderFor(hdr *tar.Header, fsPath string) error {
+	if hdr.Typeflag != tar.TypeSymlink && hdr.Typeflag != tar.TypeLink {
+		attrs := []ACLAttr{PosixACLAccess}
+		if hdr.Typeflag == tar.TypeDir {
+			attrs = append(attrs, PosixACLDefault)
+		}
+		for _, attr := range attrs {
+			a := NewACL(WithLogger(w.Logger))
+			exists, err := a.load(fsPath, attr)
+			if errors.Is(err, unix.EOPNOTSUPP) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			if err := SetTarACL(hdr, attr, a, w.Format); err != nil {
+				return err
+			}
+		}
+	}
+	return w.WriteHeader(hdr)
+}
+
+// AddTree archives the tree below root including its ACLs, with names
+// relative to root and root itself as "./". Files with multiple links
+// are archived once, further occurrences as hard links.
+func (w *TarWriter) AddTree(ctx context.Context, root string) error {
+	type inode struct{ dev, ino uint64 }
+	links := map[inode]string{}
+
+	return filepath.WalkDir(root, func(fsPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, fsPath)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(fsPath); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name = strings.TrimSuffix(hdr.Name, "/") + "/"
+			if rel == "." {
+				hdr.Name = "./"
+			}
+		}
+
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && info.Mode().IsRegular() && st.Nlink > 1 {
+			key := inode{dev: uint64(st.Dev), ino: st.Ino}
+			if target, ok := links[key]; ok {
+				hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, target, 0
+				return w.WriteHeaderFor(hdr, fsPath)
+			}
+			links[key] = hdr.Name
+		}
+
+		if err := w.WriteHeaderFor(hdr, fsPath); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(fsPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
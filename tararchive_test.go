@@ -0,0 +1,256 @@
+package acls
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTarRoundTrip(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	src := createTestTree(t, "dir/file1", "file2")
+	if err := os.WriteFile(filepath.Join(src, "file2"), []byte("content"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(src, "file2"), filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file2", filepath.Join(src, "symlink")); err != nil {
+		t.Fatal(err)
+	}
+
+	access := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 6),
+		NewEntry(TAG_ACL_USER, 4711, 4),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 4),
+		NewEntry(TAG_ACL_MASK, u, 4),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	} {
+		access.AddEntry(e)
+	}
+	def := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+		NewEntry(TAG_ACL_GROUP, 4712, 7),
+		NewEntry(TAG_ACL_MASK, u, 7),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	} {
+		def.AddEntry(e)
+	}
+	if err := access.Apply(filepath.Join(src, "file2"), PosixACLAccess); err != nil {
+		t.Fatal(err)
+	}
+	if err := def.Apply(filepath.Join(src, "dir"), PosixACLDefault); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []TarACLFormat{TarACLText, TarACLXattr} {
+		buf := &bytes.Buffer{}
+		tw := NewTarWriter(buf, format)
+		if err := tw.AddTree(context.Background(), src); err != nil {
+			t.Fatalf("AddTree() error = %v", err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		dst := t.TempDir()
+		if err := ExtractTar(context.Background(), buf, dst, ExtractOptions{}); err != nil {
+			t.Fatalf("ExtractTar() error = %v", err)
+		}
+
+		for _, p := range []string{".", "dir", "dir/file1", "file2", "link"} {
+			for _, attr := range []ACLAttr{PosixACLAccess, PosixACLDefault} {
+				if attr == PosixACLDefault && p != "." && p != "dir" {
+					continue
+				}
+				want, err := loadForCompare(filepath.Join(src, p), attr, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := loadForCompare(filepath.Join(dst, p), attr, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !got.Equal(want) {
+					t.Errorf("format %d: %s %s = %s, want %s", format, p, attr, got, want)
+				}
+			}
+		}
+		if b, err := os.ReadFile(filepath.Join(dst, "link")); err != nil || string(b) != "content" {
+			t.Errorf("format %d: link content = %q, %v", format, b, err)
+		}
+		if target, err := os.Readlink(filepath.Join(dst, "symlink")); err != nil || target != "file2" {
+			t.Errorf("format %d: symlink = %q, %v", format, target, err)
+		}
+		info, err := os.Stat(filepath.Join(dst, "file2"))
+		if err != nil || info.Mode().Perm() != 0o640 {
+			t.Errorf("format %d: file2 mode = %v, %v", format, info, err)
+		}
+	}
+}
+
+func TestExtractTar_implicitParents(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, h := range []*tar.Header{
+		{Name: "deep/a/b/f", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "links/sym", Typeflag: tar.TypeSymlink, Linkname: "../deep/a/b/f"},
+		{Name: "links/hard", Typeflag: tar.TypeLink, Linkname: "deep/a/b/f"},
+	} {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+
+	dst := t.TempDir()
+	if err := ExtractTar(context.Background(), buf, dst, ExtractOptions{}); err != nil {
+		t.Fatalf("ExtractTar() error = %v", err)
+	}
+	for _, p := range []string{"deep/a/b/f", "links/sym", "links/hard"} {
+		if _, err := os.Stat(filepath.Join(dst, p)); err != nil {
+			t.Errorf("%s not extracted: %v", p, err)
+		}
+	}
+}
+
+func TestExtractTar_modes(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	def := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 7),
+		NewEntry(TAG_ACL_OTHER, u, 5),
+	} {
+		def.AddEntry(e)
+	}
+	shared := &tar.Header{Name: "shared/", Typeflag: tar.TypeDir, Mode: 0o3775}
+	if err := SetTarACL(shared, PosixACLDefault, def, TarACLText); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, h := range []*tar.Header{
+		shared,
+		{Name: "shared/tool", Typeflag: tar.TypeReg, Mode: 0o4755},
+		// the extraction fails here, after shared/ has been queued
+		{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0o644},
+	} {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+
+	dst := t.TempDir()
+	if err := ExtractTar(context.Background(), buf, dst, ExtractOptions{}); err == nil {
+		t.Fatal("ExtractTar() succeeded, want error")
+	}
+	for p, want := range map[string]os.FileMode{
+		"shared":      os.ModeDir | os.ModeSetgid | os.ModeSticky | 0o775,
+		"shared/tool": os.ModeSetuid | 0o755,
+	} {
+		info, err := os.Stat(filepath.Join(dst, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != want {
+			t.Errorf("%s mode = %v, want %v", p, info.Mode(), want)
+		}
+	}
+	got, err := loadForCompare(filepath.Join(dst, "shared"), PosixACLDefault, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(def) {
+		t.Errorf("default ACL of shared = %s, want %s", got, def)
+	}
+}
+
+func TestFinishTarEntry_symlinkSwap(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	def := NewACL()
+	def.AddEntry(NewEntry(TAG_ACL_USER_OBJ, ACL_UNDEFINED_ID, 7))
+	def.AddEntry(NewEntry(TAG_ACL_GROUP_OBJ, ACL_UNDEFINED_ID, 7))
+	def.AddEntry(NewEntry(TAG_ACL_OTHER, ACL_UNDEFINED_ID, 7))
+	hdr := &tar.Header{Name: "d/", Typeflag: tar.TypeDir, Mode: 0o777}
+	SetTarACL(hdr, PosixACLDefault, def, TarACLText)
+
+	// the directory got replaced by a symlink leaving the root
+	if err := os.Symlink(outside, filepath.Join(dir, "d")); err != nil {
+		t.Fatal(err)
+	}
+	if err := finishTarEntry(root, "d", hdr, ExtractOptions{}, discardLogger); err == nil {
+		t.Error("finishTarEntry() followed a symlink out of the root")
+	}
+	if got, err := loadForCompare(outside, PosixACLDefault, nil); err != nil || len(got.entries) != 0 {
+		t.Errorf("default ACL outside the root = %v, %v", got, err)
+	}
+	if info, err := os.Stat(outside); err != nil || info.Mode().Perm() == 0o777 {
+		t.Errorf("mode outside the root changed: %v, %v", info, err)
+	}
+}
+
+func TestExtractTar_escape(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+		wantErr string
+	}{
+		{
+			name:    "parent path",
+			headers: []*tar.Header{{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0o644}},
+			wantErr: "outside",
+		},
+		{
+			name:    "absolute path",
+			headers: []*tar.Header{{Name: "/evil", Typeflag: tar.TypeReg, Mode: 0o644}},
+			wantErr: "outside",
+		},
+		{
+			name: "through symlink",
+			headers: []*tar.Header{
+				{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: os.TempDir()},
+				{Name: "escape/evil", Typeflag: tar.TypeReg, Mode: 0o644},
+			},
+			wantErr: "escape/evil",
+		},
+		{
+			name: "invalid acl",
+			headers: []*tar.Header{{Name: "file", Typeflag: tar.TypeReg, Mode: 0o644,
+				PAXRecords: map[string]string{PAXSchilyACLAccess: "bogus"}}},
+			wantErr: "SCHILY.acl.access",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			tw := tar.NewWriter(buf)
+			for _, h := range tt.headers {
+				if err := tw.WriteHeader(h); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+			err := ExtractTar(context.Background(), buf, t.TempDir(), ExtractOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ExtractTar() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package acls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OverflowID is the ID the kernel reports for IDs
+// not mapped into a user namespace
+const OverflowID uint32 = 65534
+
+// IDMapRange is a line of a uid_map or gid_map file, mapping Count
+// IDs starting at Inside in the namespace to IDs starting at Outside
+// in the parent namespace
+type IDMapRange struct {
+	Inside  uint32
+	Outside uint32
+	Count   uint32
+}
+
+// NamespaceIDMap is the content of a uid_map or gid_map file
+type NamespaceIDMap []IDMapRange
+
+// ParseNamespaceIDMap parses the format of /proc/<pid>/uid_map
+// and /proc/<pid>/gid_map
+func ParseNamespaceIDMap(r io.Reader) (NamespaceIDMap, error) {
+	result := NamespaceIDMap{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("id map line %d: expected 3 fields, got %d", line, len(fields))
+		}
+		values := [3]uint32{}
+		for i, f := range fields {
+			v, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("id map line %d: %w", line, err)
+			}
+			values[i] = uint32(v)
+		}
+		result = append(result, IDMapRange{Inside: values[0], Outside: values[1], Count: values[2]})
+	}
+	return result, scanner.Err()
+}
+
+// LoadNamespaceIDMap parses the uid_map or gid_map file at path
+func LoadNamespaceIDMap(path string) (NamespaceIDMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseNamespaceIDMap(f)
+}
+
+// ToHost translates an ID of the namespace to the parent namespace,
+// ok is false if the ID is not mapped
+func (m NamespaceIDMap) ToHost(id uint32) (uint32, bool) {
+	for _, r := range m {
+		if id >= r.Inside && uint64(id) < uint64(r.Inside)+uint64(r.Count) {
+			return r.Outside + (id - r.Inside), true
+		}
+	}
+	return OverflowID, false
+}
+
+// ToNamespace translates an ID of the parent namespace to the
+// namespace, ok is false if the ID is not mapped
+func (m NamespaceIDMap) ToNamespace(id uint32) (uint32, bool) {
+	for _, r := range m {
+		if id >= r.Outside && uint64(id) < uint64(r.Outside)+uint64(r.Count) {
+			return r.Inside + (id - r.Outside), true
+		}
+	}
+	return OverflowID, false
+}
+
+// UserNamespace holds the ID maps of a user namespace
+type UserNamespace struct {
+	UIDs NamespaceIDMap
+	GIDs NamespaceIDMap
+}
+
+// LoadUserNamespace loads the ID maps of the user namespace
+// of the process with the given pid, 0 denotes the own process
+func LoadUserNamespace(pid int) (*UserNamespace, error) {
+	dir := "/proc/self"
+	if pid != 0 {
+		dir = "/proc/" + strconv.Itoa(pid)
+	}
+	uids, err := LoadNamespaceIDMap(dir + "/uid_map")
+	if err != nil {
+		return nil, err
+	}
+	gids, err := LoadNamespaceIDMap(dir + "/gid_map")
+	if err != nil {
+		return nil, err
+	}
+	return &UserNamespace{UIDs: uids, GIDs: gids}, nil
+}
+
+// ToHost returns a copy of a with the qualifiers of the named entries
+// translated from namespace to host IDs. Entries that cannot be mapped
+// are left out of the copy and returned as they were in a, the mask is
+// kept as is.
+func (n *UserNamespace) ToHost(a *ACL) (*ACL, []*ACLEntry) {
+	return n.translate(a, NamespaceIDMap.ToHost)
+}
+
+// ToNamespace returns a copy of a with the qualifiers of the named
+// entries translated from host to namespace IDs. Entries that cannot
+// be mapped are left out of the copy and returned as they were in a.
+func (n *UserNamespace) ToNamespace(a *ACL) (*ACL, []*ACLEntry) {
+	return n.translate(a, NamespaceIDMap.ToNamespace)
+}
+
+// translate remaps a with f applied to the uid or gid map. Unmappable
+// entries are removed rather than set to OverflowID, which would grant
+// their permissions to the overflow user.
+func (n *UserNamespace) translate(a *ACL, f func(NamespaceIDMap, uint32) (uint32, bool)) (*ACL, []*ACLEntry) {
+	idMap := func(tag Tag) NamespaceIDMap {
+		if tag == TAG_ACL_GROUP {
+			return n.GIDs
+		}
+		return n.UIDs
+	}
+	result := a.Clone()
+	unmappable := []*ACLEntry{}
+	for _, e := range result.GetEntries() {
+		if e.tag != TAG_ACL_USER && e.tag != TAG_ACL_GROUP {
+			continue
+		}
+		if _, ok := f(idMap(e.tag), e.id); !ok {
+			unmappable = append(unmappable, result.DeleteEntry(e))
+		}
+	}
+	result.Remap(func(tag Tag, id uint32) (uint32, bool) {
+		return f(idMap(tag), id)
+	})
+	return result, unmappable
+}
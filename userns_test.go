@@ -0,0 +1,110 @@
+package acls
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNamespaceIDMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    NamespaceIDMap
+		wantErr bool
+	}{
+		{
+			name:  "identity",
+			input: "         0          0 4294967295\n",
+			want:  NamespaceIDMap{{Inside: 0, Outside: 0, Count: math.MaxUint32}},
+		},
+		{
+			name:  "container",
+			input: "0 100000 65536\n65536 1000 1\n",
+			want:  NamespaceIDMap{{Inside: 0, Outside: 100000, Count: 65536}, {Inside: 65536, Outside: 1000, Count: 1}},
+		},
+		{name: "empty", input: "", want: NamespaceIDMap{}},
+		{name: "missing field", input: "0 100000\n", wantErr: true},
+		{name: "not a number", input: "0 x 1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNamespaceIDMap(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNamespaceIDMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNamespaceIDMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceIDMap_translate(t *testing.T) {
+	m := NamespaceIDMap{{Inside: 0, Outside: 100000, Count: 65536}}
+	if id, ok := m.ToHost(1000); !ok || id != 101000 {
+		t.Errorf("ToHost(1000) = %d, %v", id, ok)
+	}
+	if id, ok := m.ToHost(65536); ok || id != OverflowID {
+		t.Errorf("ToHost(65536) = %d, %v", id, ok)
+	}
+	if id, ok := m.ToNamespace(101000); !ok || id != 1000 {
+		t.Errorf("ToNamespace(101000) = %d, %v", id, ok)
+	}
+	if id, ok := m.ToNamespace(1000); ok || id != OverflowID {
+		t.Errorf("ToNamespace(1000) = %d, %v", id, ok)
+	}
+}
+
+func TestUserNamespace_ToHost(t *testing.T) {
+	u := uint32(math.MaxUint32)
+	n := &UserNamespace{
+		UIDs: NamespaceIDMap{{Inside: 0, Outside: 100000, Count: 65536}},
+		GIDs: NamespaceIDMap{{Inside: 0, Outside: 200000, Count: 65536}},
+	}
+	a := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_USER, 1000, 6),
+		NewEntry(TAG_ACL_GROUP, 1000, 4),
+		NewEntry(TAG_ACL_GROUP, 70000, 1),
+		NewEntry(TAG_ACL_GROUP, 70001, 2),
+		NewEntry(TAG_ACL_MASK, u, 7),
+	}}
+	got, unmappable := n.ToHost(a)
+	// unmappable entries are dropped, not merged into an OverflowID entry
+	want := &ACL{version: 2, entries: []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_USER, 101000, 6),
+		NewEntry(TAG_ACL_GROUP, 201000, 4),
+		NewEntry(TAG_ACL_MASK, u, 7),
+	}}
+	if !got.Equal(want) {
+		t.Errorf("ToHost() = %v, want %v", got, want)
+	}
+	if len(unmappable) != 2 || !unmappable[0].Equal(NewEntry(TAG_ACL_GROUP, 70000, 1)) || !unmappable[1].Equal(NewEntry(TAG_ACL_GROUP, 70001, 2)) {
+		t.Errorf("ToHost() unmappable = %v", unmappable)
+	}
+	if e := got.GetEntry(NewEntry(TAG_ACL_GROUP, OverflowID, 0)); e != nil {
+		t.Errorf("ToHost() granted %s to the overflow group", e)
+	}
+
+	got.AddEntry(NewEntry(TAG_ACL_USER, 50, 4))
+	back, unmappable := n.ToNamespace(got)
+	if len(unmappable) != 1 || !unmappable[0].Equal(NewEntry(TAG_ACL_USER, 50, 4)) {
+		t.Errorf("ToNamespace() unmappable = %v", unmappable)
+	}
+	if back.GetEntry(NewEntry(TAG_ACL_USER, 1000, 0)) == nil || back.GetEntry(NewEntry(TAG_ACL_GROUP, 1000, 0)) == nil {
+		t.Errorf("ToNamespace() = %v", back)
+	}
+}
+
+func TestLoadUserNamespace(t *testing.T) {
+	n, err := LoadUserNamespace(0)
+	if err != nil {
+		t.Skipf("user namespace maps not available: %v", err)
+	}
+	if len(n.UIDs) == 0 || len(n.GIDs) == 0 {
+		t.Errorf("expected at least one range, got %+v", n)
+	}
+}
@@ -0,0 +1,100 @@
+package acls
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// POSIX_ACL_XATTR_VERSION is the version of the
+// system.posix_acl_* xattr format used by Linux
+const POSIX_ACL_XATTR_VERSION uint32 = 0x0002
+
+// UnsupportedVersionError is returned for ACLs of a
+// version no ACLCodec is registered for
+type UnsupportedVersionError struct {
+	Version uint32
+}
+
+// Error implements error
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported acl xattr version %d", e.Version)
+}
+
+// ACLCodec encodes and decodes the entries of one version of the
+// POSIX ACL xattr format. The version header itself is handled by
+// the ACL, the codec only sees the data following it.
+type ACLCodec interface {
+	// Version is the version in the xattr header the codec handles
+	Version() uint32
+	// DecodeEntries decodes the entries following the header
+	DecodeEntries(b []byte) ([]*ACLEntry, error)
+	// EncodeEntries writes the entries, already sorted, to result
+	EncodeEntries(result *bytes.Buffer, entries []*ACLEntry)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[uint32]ACLCodec{POSIX_ACL_XATTR_VERSION: posixACLCodec{}}
+)
+
+// RegisterACLCodec registers the codec for its version, replacing
+// a codec registered for the same version before
+func RegisterACLCodec(c ACLCodec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Version()] = c
+}
+
+// LookupACLCodec returns the codec registered for the version or an
+// *UnsupportedVersionError
+func LookupACLCodec(version uint32) (ACLCodec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[version]
+	if !ok {
+		return nil, &UnsupportedVersionError{Version: version}
+	}
+	return c, nil
+}
+
+// WithVersion sets the xattr format version of the ACL. Applying the
+// ACL fails with an *UnsupportedVersionError if no codec is
+// registered for it.
+func WithVersion(version uint32) ACLOption {
+	return func(a *ACL) {
+		a.version = version
+	}
+}
+
+// Version returns the xattr format version of the ACL
+func (a *ACL) Version() uint32 {
+	return a.version
+}
+
+// posixACLCodec implements version 2, the format used by Linux
+type posixACLCodec struct{}
+
+func (posixACLCodec) Version() uint32 { return POSIX_ACL_XATTR_VERSION }
+
+func (posixACLCodec) DecodeEntries(b []byte) ([]*ACLEntry, error) {
+	if len(b)%aclEntrySize != 0 {
+		return nil, fmt.Errorf("malformed data")
+	}
+	// a single backing array for all entries
+	values := make([]ACLEntry, len(b)/aclEntrySize)
+	entries := make([]*ACLEntry, len(values))
+	for i := range values {
+		if _, err := values[i].parse(b[i*aclEntrySize:]); err != nil {
+			return nil, err
+		}
+		entries[i] = &values[i]
+	}
+	return entries, nil
+}
+
+func (posixACLCodec) EncodeEntries(result *bytes.Buffer, entries []*ACLEntry) {
+	for _, e := range entries {
+		result.Write(e.AppendBinary(result.AvailableBuffer()))
+	}
+}
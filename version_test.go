@@ -0,0 +1,115 @@
+package acls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// testCodec encodes entries as tag, perm and id in big endian
+type testCodec struct{}
+
+func (testCodec) Version() uint32 { return 0x7f }
+
+func (testCodec) DecodeEntries(b []byte) ([]*ACLEntry, error) {
+	entries := []*ACLEntry{}
+	for ; len(b) >= 8; b = b[8:] {
+		entries = append(entries, NewEntry(Tag(binary.BigEndian.Uint16(b)), binary.BigEndian.Uint32(b[4:]), binary.BigEndian.Uint16(b[2:])))
+	}
+	return entries, nil
+}
+
+func (testCodec) EncodeEntries(result *bytes.Buffer, entries []*ACLEntry) {
+	for _, e := range entries {
+		binary.Write(result, binary.BigEndian, e.tag)
+		binary.Write(result, binary.BigEndian, e.perm)
+		binary.Write(result, binary.BigEndian, e.id)
+	}
+}
+
+func TestACL_parseVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		hex         string
+		wantVersion uint32
+		wantEntries int
+		wantErr     bool
+	}{
+		{name: "version 2", hex: "0200000001000700ffffffff", wantVersion: 2, wantEntries: 1},
+		{name: "header only", hex: "02000000", wantVersion: 2},
+		{name: "version 1", hex: "0100000001000700ffffffff", wantErr: true},
+		{name: "big endian version", hex: "0000000201000700ffffffff", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.hex)
+			a := NewACL()
+			err := a.parse(b)
+			if tt.wantErr {
+				var verr *UnsupportedVersionError
+				if !errors.As(err, &verr) {
+					t.Fatalf("parse() error = %v, want UnsupportedVersionError", err)
+				}
+				if verr.Version != binary.LittleEndian.Uint32(b) {
+					t.Errorf("UnsupportedVersionError.Version = %d", verr.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			if a.Version() != tt.wantVersion || len(a.entries) != tt.wantEntries {
+				t.Errorf("parse() = version %d, %d entries", a.Version(), len(a.entries))
+			}
+		})
+	}
+}
+
+func TestRegisterACLCodec(t *testing.T) {
+	RegisterACLCodec(testCodec{})
+	t.Cleanup(func() {
+		codecsMu.Lock()
+		delete(codecs, testCodec{}.Version())
+		codecsMu.Unlock()
+	})
+
+	a := NewACL(WithVersion(0x7f))
+	a.AddEntry(NewEntry(TAG_ACL_USER_OBJ, ACL_UNDEFINED_ID, 6))
+	a.AddEntry(NewEntry(TAG_ACL_GROUP_OBJ, ACL_UNDEFINED_ID, 4))
+	buf := &bytes.Buffer{}
+	if err := a.ToByteSlice(buf); err != nil {
+		t.Fatalf("ToByteSlice() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(buf.Bytes()), "7f00000000010006ffffffff00040004ffffffff"; got != want {
+		t.Errorf("ToByteSlice() = %s, want %s", got, want)
+	}
+	got := NewACL()
+	if err := got.parse(buf.Bytes()); err != nil {
+		t.Fatalf("parse() error = %v", err)
+	}
+	if !got.Equal(a) {
+		t.Errorf("parse() = %s, want %s", got, a)
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	a := NewACL(WithVersion(1))
+	a.AddEntry(NewEntry(TAG_ACL_USER_OBJ, ACL_UNDEFINED_ID, 6))
+	fsPath := filepath.Join(createTestTree(t, "file"), "file")
+	var verr *UnsupportedVersionError
+	if err := a.Apply(fsPath, PosixACLAccess); !errors.As(err, &verr) {
+		t.Errorf("Apply() error = %v, want UnsupportedVersionError", err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":1,"entries":[]}`), NewACL()); !errors.As(err, &verr) {
+		t.Errorf("UnmarshalJSON() error = %v, want UnsupportedVersionError", err)
+	}
+	j := NewACL(WithVersion(5))
+	if err := json.Unmarshal([]byte(`{"entries":[]}`), j); err != nil || j.Version() != POSIX_ACL_XATTR_VERSION {
+		t.Errorf("UnmarshalJSON() without version = %d, %v", j.Version(), err)
+	}
+}
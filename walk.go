@@ -0,0 +1,163 @@
+package acls
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// walkFunc is called for every path visited by walkTree.
+// For directories err carries the error of reading the directory
+// listing, in which case the children of the directory are not visited.
+type walkFunc func(fsPath string, d fs.DirEntry, err error)
+
+// walker walks a directory tree with a bounded number of workers.
+// Paths waiting to be visited are queued in memory, so the number
+// of goroutines does not depend on the size of the tree.
+type walker struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []walkItem
+	pending int
+	stopped bool
+
+	visit walkFunc
+	tick  <-chan time.Time
+}
+
+type walkItem struct {
+	path string
+	d    fs.DirEntry
+}
+
+// rateTicker returns a channel ticking rate times per second, along
+// with the function stopping it. The channel is nil, meaning unlimited,
+// for rates of 0 or less and for rates above one per nanosecond, which
+// no ticker can honor.
+func rateTicker(rate int) (<-chan time.Time, func()) {
+	if rate <= 0 || int64(rate) > int64(time.Second) {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	return ticker.C, ticker.Stop
+}
+
+// walkTree visits root and everything below it concurrently using the
+// given number of workers (defaults to GOMAXPROCS if < 1). If rate is > 0,
+// at most rate paths are visited per second. Symbolic links below root
+// are not followed and not visited. visit must be safe for concurrent use.
+// The returned error is either the error determining root or the
+// error of the context if it got cancelled.
+func walkTree(ctx context.Context, root string, workers int, rate int, visit walkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	w := &walker{
+		visit:   visit,
+		queue:   []walkItem{{path: root, d: fs.FileInfoToDirEntry(info)}},
+		pending: 1,
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	tick, stopTick := rateTicker(rate)
+	defer stopTick()
+	w.tick = tick
+
+	// wake up all waiting workers if the context is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		w.stopped = true
+		w.mu.Unlock()
+		w.cond.Broadcast()
+	})
+	defer stop()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// work processes queued items until the tree is exhausted
+// or the walk got stopped
+func (w *walker) work(ctx context.Context) {
+	for {
+		item, ok := w.next()
+		if !ok {
+			return
+		}
+		if ctx.Err() != nil {
+			w.done(nil)
+			return
+		}
+		if w.tick != nil {
+			select {
+			case <-w.tick:
+			case <-ctx.Done():
+				w.done(nil)
+				return
+			}
+		}
+		w.done(w.process(item))
+	}
+}
+
+// next blocks until an item is available and returns it.
+// false is returned if there is nothing left to do.
+func (w *walker) next() (walkItem, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && w.pending > 0 && !w.stopped {
+		w.cond.Wait()
+	}
+	if w.stopped || len(w.queue) == 0 {
+		return walkItem{}, false
+	}
+	item := w.queue[len(w.queue)-1]
+	w.queue = w.queue[:len(w.queue)-1]
+	return item, true
+}
+
+// process visits a single item and returns its children
+func (w *walker) process(item walkItem) []walkItem {
+	if !item.d.IsDir() {
+		w.visit(item.path, item.d, nil)
+		return nil
+	}
+	entries, err := os.ReadDir(item.path)
+	w.visit(item.path, item.d, err)
+
+	children := make([]walkItem, 0, len(entries))
+	for _, e := range entries {
+		if e.Type()&fs.ModeSymlink != 0 {
+			continue
+		}
+		children = append(children, walkItem{path: filepath.Join(item.path, e.Name()), d: e})
+	}
+	return children
+}
+
+// done marks an item as finished and queues its children
+func (w *walker) done(children []walkItem) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.queue = append(w.queue, children...)
+	w.pending += len(children) - 1
+	w.cond.Broadcast()
+}
@@ -0,0 +1,123 @@
+package acls
+
+import (
+	"context"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createTestTree creates a directory tree below a temp dir
+// and returns its root. All given paths are relative to the root,
+// a trailing slash denotes a directory.
+func createTestTree(t *testing.T, paths ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, p := range paths {
+		full := filepath.Join(root, p)
+		if p[len(p)-1] == '/' {
+			if err := os.MkdirAll(full, 0o750); err != nil {
+				t.Fatalf("failed creating directory %q: %v", full, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0o750); err != nil {
+			t.Fatalf("failed creating directory %q: %v", filepath.Dir(full), err)
+		}
+		if err := os.WriteFile(full, nil, 0o640); err != nil {
+			t.Fatalf("failed creating file %q: %v", full, err)
+		}
+	}
+	return root
+}
+
+func TestWalkTree(t *testing.T) {
+	root := createTestTree(t, "a/b/c/file1", "a/file2", "d/", "file3")
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "link")); err != nil {
+		t.Fatalf("failed creating symlink: %v", err)
+	}
+
+	for _, workers := range []int{0, 1, 8} {
+		mu := sync.Mutex{}
+		visited := []string{}
+		err := walkTree(context.Background(), root, workers, 0, func(fsPath string, d fs.DirEntry, err error) {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %v", fsPath, err)
+			}
+			rel, _ := filepath.Rel(root, fsPath)
+			mu.Lock()
+			visited = append(visited, rel)
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatalf("walkTree() unexpected error %v", err)
+		}
+		sort.Strings(visited)
+		want := []string{".", "a", "a/b", "a/b/c", "a/b/c/file1", "a/file2", "d", "file3"}
+		if len(visited) != len(want) {
+			t.Fatalf("workers %d: visited %v, want %v", workers, visited, want)
+		}
+		for i := range want {
+			if visited[i] != want[i] {
+				t.Errorf("workers %d: visited %v, want %v", workers, visited, want)
+				break
+			}
+		}
+	}
+}
+
+func TestWalkTree_Errors(t *testing.T) {
+	if err := walkTree(context.Background(), filepath.Join(t.TempDir(), "missing"), 1, 0, func(string, fs.DirEntry, error) {}); err == nil {
+		t.Errorf("expected error for missing root")
+	}
+
+	root := createTestTree(t, "a/file1", "b/file2")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := walkTree(ctx, root, 2, 0, func(string, fs.DirEntry, error) {}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWalkTree_RateLimit(t *testing.T) {
+	root := createTestTree(t, "file1", "file2", "file3", "file4")
+	start := time.Now()
+	if err := walkTree(context.Background(), root, 4, 50, func(string, fs.DirEntry, error) {}); err != nil {
+		t.Fatalf("walkTree() unexpected error %v", err)
+	}
+	// five paths at 50 per second need at least 4 intervals of 20ms
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("rate limit not applied, walk took %s", elapsed)
+	}
+}
+
+func TestRateTicker(t *testing.T) {
+	tests := []struct {
+		rate     int
+		wantTick bool
+	}{
+		{rate: -1, wantTick: false},
+		{rate: 0, wantTick: false},
+		{rate: 1, wantTick: true},
+		{rate: int(time.Second), wantTick: true},
+		{rate: int(time.Second) + 1, wantTick: false},
+		{rate: math.MaxInt, wantTick: false},
+	}
+	for _, tt := range tests {
+		tick, stop := rateTicker(tt.rate)
+		if got := tick != nil; got != tt.wantTick {
+			t.Errorf("rateTicker(%d) ticking = %v, want %v", tt.rate, got, tt.wantTick)
+		}
+		stop()
+	}
+
+	root := createTestTree(t, "file1")
+	if err := walkTree(context.Background(), root, 1, math.MaxInt, func(string, fs.DirEntry, error) {}); err != nil {
+		t.Fatalf("walkTree() unexpected error %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package acls
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// xattrBufferSize fits POSIX ACLs of up to 31 entries,
+	// larger ones grow the buffer
+	xattrBufferSize = 256
+	// xattrSizeMax is the largest xattr value Linux supports
+	xattrSizeMax = 64 * 1024
+	// xattrRetries limits the reads if the xattr keeps growing
+	// between the size query and the read
+	xattrRetries = 4
+)
+
+// xattrBuffers pools the buffers xattrs are read into
+var xattrBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, xattrBufferSize)
+		return &b
+	},
+}
+
+// getXattrBuffer returns a buffer of xattrBufferSize from the pool
+func getXattrBuffer() *[]byte {
+	return xattrBuffers.Get().(*[]byte)
+}
+
+// putXattrBuffer returns buf to the pool unless it was grown, so
+// reading a single large xattr does not keep large buffers alive
+func putXattrBuffer(buf *[]byte) {
+	if len(*buf) == xattrBufferSize {
+		xattrBuffers.Put(buf)
+	}
+}
+
+// getxattr reads the xattr of fsPath into *buf with a single Getxattr
+// call in the common case. If the buffer is too small it is grown to
+// the current size of the xattr and the read is retried, as the xattr
+// may change between the calls. An empty buffer is allocated first,
+// as Getxattr returns the size instead of reading into it. The
+// returned value aliases *buf.
+func getxattr(fsPath string, attr string, buf *[]byte) ([]byte, error) {
+	if len(*buf) == 0 {
+		*buf = make([]byte, xattrBufferSize)
+	}
+	for range xattrRetries {
+		n, err := unix.Getxattr(fsPath, attr, *buf)
+		if err == nil && n <= len(*buf) {
+			return (*buf)[:n], nil
+		}
+		if err != nil && err != unix.ERANGE {
+			return nil, err
+		}
+		size, err := unix.Getxattr(fsPath, attr, nil)
+		if err != nil {
+			return nil, err
+		}
+		// the xattr may have grown again in the meantime
+		size = max(size, 2*len(*buf))
+		*buf = make([]byte, min(size, xattrSizeMax))
+	}
+	return nil, unix.ERANGE
+}
+
+// readXattr reads the xattr of fsPath into a pooled buffer and
+// passes the value to f, which must not retain it
+func readXattr(fsPath string, attr ACLAttr, f func(value []byte) error) error {
+	buf := getXattrBuffer()
+	defer putXattrBuffer(buf)
+	value, err := getxattr(fsPath, string(attr), buf)
+	if err != nil {
+		return err
+	}
+	return f(value)
+}
+
+// ACLLoader loads the ACLs of many paths, reusing its read buffer
+// across them. It is not safe for concurrent use, use one loader
+// per goroutine.
+type ACLLoader struct {
+	buf []byte
+	// Logger is set on the loaded ACLs, nothing is logged if nil
+	Logger *slog.Logger
+}
+
+// NewACLLoader returns an ACLLoader
+func NewACLLoader() *ACLLoader {
+	return &ACLLoader{buf: make([]byte, xattrBufferSize)}
+}
+
+// Load loads the attr of fsPath like ACL.Load does, bootstrapping
+// the access ACL of paths without ACL from the file mode
+func (l *ACLLoader) Load(fsPath string, attr ACLAttr) (*ACL, error) {
+	a := NewACL(WithLogger(l.Logger))
+	if _, err := a.loadWith(fsPath, attr, &l.buf); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadEntries decodes the attr of fsPath into dst, see
+// DecodeEntryValues. exists is false and dst is returned unchanged
+// if fsPath has no such ACL, no entries are bootstrapped.
+func (l *ACLLoader) LoadEntries(dst []ACLEntry, fsPath string, attr ACLAttr) (entries []ACLEntry, exists bool, err error) {
+	value, err := getxattr(fsPath, string(attr), &l.buf)
+	if errors.Is(err, unix.ENODATA) {
+		return dst, false, nil
+	}
+	if err != nil {
+		return dst, false, err
+	}
+	entries, _, err = DecodeEntryValues(dst, value)
+	return entries, err == nil, err
+}
+
+// LoadAll loads the attr of all paths. The ACLs are in the order of
+// paths, nil for the paths listed in the errors.
+func (l *ACLLoader) LoadAll(paths []string, attr ACLAttr) ([]*ACL, []*BulkError) {
+	result := make([]*ACL, len(paths))
+	errs := []*BulkError{}
+	for i, p := range paths {
+		a, err := l.Load(p, attr)
+		if err != nil {
+			errs = append(errs, &BulkError{Path: p, Attr: attr, Err: err})
+			continue
+		}
+		result[i] = a
+	}
+	return result, errs
+}
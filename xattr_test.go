@@ -0,0 +1,128 @@
+package acls
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// largeTestACL returns an ACL exceeding the pooled buffer size
+func largeTestACL(t *testing.T) *ACL {
+	t.Helper()
+	u := uint32(math.MaxUint32)
+	a := NewACL()
+	for _, e := range []*ACLEntry{
+		NewEntry(TAG_ACL_USER_OBJ, u, 7),
+		NewEntry(TAG_ACL_GROUP_OBJ, u, 5),
+		NewEntry(TAG_ACL_MASK, u, 7),
+		NewEntry(TAG_ACL_OTHER, u, 0),
+	} {
+		a.AddEntry(e)
+	}
+	for id := uint32(5000); id < 5040; id++ {
+		a.AddEntry(NewEntry(TAG_ACL_USER, id, 4))
+	}
+	return a
+}
+
+func TestGetxattr(t *testing.T) {
+	root := createTestTree(t, "file")
+	fsPath := filepath.Join(root, "file")
+	a := largeTestACL(t)
+	if err := a.Apply(fsPath, PosixACLAccess); err != nil {
+		t.Fatal(err)
+	}
+	want, err := a.AppendBinary(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) <= xattrBufferSize {
+		t.Fatalf("test ACL of %d bytes fits the buffer", len(want))
+	}
+
+	buf := make([]byte, 8)
+	got, err := getxattr(fsPath, string(PosixACLAccess), &buf)
+	if err != nil {
+		t.Fatalf("getxattr() error = %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("getxattr() = %x, want %x", got, want)
+	}
+	if len(buf) < len(want) {
+		t.Errorf("buffer not grown, len %d", len(buf))
+	}
+
+	loaded := NewACL()
+	if err := loaded.Load(fsPath, PosixACLAccess); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !loaded.Equal(a) {
+		t.Errorf("Load() = %s, want %s", loaded, a)
+	}
+
+	// Getxattr returns the size for an empty buffer instead of reading
+	var empty []byte
+	if got, err := getxattr(fsPath, string(PosixACLAccess), &empty); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("getxattr() with empty buffer = %x, %v", got, err)
+	}
+
+	if _, err := getxattr(fsPath, string(PosixACLDefault), &buf); !errors.Is(err, unix.ENODATA) {
+		t.Errorf("getxattr() of missing attr error = %v", err)
+	}
+}
+
+func TestACLLoader(t *testing.T) {
+	root := createTestTree(t, "dir/", "file")
+	file := filepath.Join(root, "file")
+	a := largeTestACL(t)
+	if err := a.Apply(file, PosixACLAccess); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewACLLoader()
+	got, err := l.Load(file, PosixACLAccess)
+	if err != nil || !got.Equal(a) {
+		t.Errorf("Load() = %v, %v, want %s", got, err, a)
+	}
+
+	entries, exists, err := l.LoadEntries(nil, file, PosixACLAccess)
+	if err != nil || !exists || len(entries) != len(a.entries) {
+		t.Errorf("LoadEntries() = %d entries, %t, %v", len(entries), exists, err)
+	}
+	entries, exists, err = l.LoadEntries(entries[:0], filepath.Join(root, "dir"), PosixACLDefault)
+	if err != nil || exists || len(entries) != 0 {
+		t.Errorf("LoadEntries() of missing attr = %d entries, %t, %v", len(entries), exists, err)
+	}
+
+	// the zero value is usable as well
+	zero := &ACLLoader{}
+	if got, err := zero.Load(file, PosixACLAccess); err != nil || !got.Equal(a) {
+		t.Errorf("Load() of zero value loader = %v, %v, want %s", got, err, a)
+	}
+	if entries, exists, err := (&ACLLoader{}).LoadEntries(nil, file, PosixACLAccess); err != nil || !exists || len(entries) != len(a.entries) {
+		t.Errorf("LoadEntries() of zero value loader = %d entries, %t, %v", len(entries), exists, err)
+	}
+
+	paths := []string{file, filepath.Join(root, "missing"), filepath.Join(root, "dir")}
+	acls, errs := l.LoadAll(paths, PosixACLAccess)
+	if len(acls) != 3 || acls[0] == nil || acls[1] != nil || acls[2] == nil {
+		t.Errorf("LoadAll() = %v", acls)
+	}
+	if len(errs) != 1 || errs[0].Path != paths[1] {
+		t.Errorf("LoadAll() errors = %v", errs)
+	}
+}
+
+func TestPutXattrBuffer(t *testing.T) {
+	grown := make([]byte, 2*xattrBufferSize)
+	putXattrBuffer(&grown)
+	for range 10 {
+		if b := getXattrBuffer(); len(*b) != xattrBufferSize {
+			t.Fatalf("pool returned buffer of %d bytes", len(*b))
+		}
+	}
+}